@@ -2,67 +2,52 @@ package main
 
 import (
 	"os"
-	"math"
 	"github.com/jgbaldwinbrown/slide/pkg"
-	"bufio"
 	"fmt"
 	"flag"
 )
 
-func AbsFilter(s slide.BedOutputScanner) *slide.BedEntryScanner {
-	outc := make(chan slide.BedEntry, 256)
-
-	go func() {
-		for s.Scan() {
-			entry := s.Entry()
-			entry.Val = math.Abs(entry.Val)
-			outc <- entry
-		}
-		close(outc)
-	}()
-
-	return slide.NewBedEntryScanner(outc)
-}
-
-func Log10(s slide.BedOutputScanner) *slide.BedEntryScanner {
-	outc := make(chan slide.BedEntry, 256)
-
-	go func() {
-		for s.Scan() {
-			entry := s.Entry()
-			entry.Other = math.Log10(entry.Val)
-			outc <- entry
-		}
-		close(outc)
-	}()
-
-	return slide.NewBedEntryScanner(outc)
-}
-
-func PrintWins(s slide.BedOutputScanner) error {
-	w := bufio.NewWriter(os.Stdout)
-	defer w.Flush()
-
-	for s.Scan() {
-		entry := s.Entry()
-		_, e := fmt.Printf("%v\t%d\t%d\t%v\t%v\n", entry.Chrom, int(entry.Left), int(entry.Right), entry.Val, entry.Other.(float64))
-		if e != nil {
-			return fmt.Errorf("PrintWins: %w", e)
-		}
+func PrintWins(s slide.BedOutputScanner, w slide.EntryWriter) error {
+	e := slide.WriteEntries(w, s)
+	if e != nil {
+		return fmt.Errorf("PrintWins: %w", e)
 	}
 	return nil
 }
 
 func main() {
-	sizep := flag.Int("s", 1, "Window size")
-	stepp := flag.Int("t", 1, "Window step")
+	var wf slide.WriterFlags
+	win := slide.WindowFlags{Size: "1", Step: "1"}
+	var pre, post slide.Exprs
+	win.Register(flag.CommandLine)
+	win.RegisterLegacy(flag.CommandLine, "s", "t")
+	flag.Var(&pre, "pre", "Expression applied to absolute input values before windowing (repeatable)")
+	flag.Var(&post, "post", "Expression applied to window means before the log10 column is added (repeatable)")
+	wf.Register(flag.CommandLine)
 	flag.Parse()
 
+	size, step, err := win.Parse()
+	if err != nil {
+		panic(err)
+	}
+
+	w, err := wf.NewWriter(os.Stdout)
+	if err != nil {
+		panic(err)
+	}
+
 	bedscan := slide.NewBedReaderScanner(os.Stdin)
-	abs := AbsFilter(bedscan)
-	wins := slide.NewBedEntryScanner(slide.SlidingEntryMeans(abs, float64(*sizep), float64(*stepp)))
-	logged := Log10(wins)
-	err := PrintWins(logged)
+	abs := pre.Apply(slide.Abs(bedscan))
+	wins := post.Apply(slide.NewBedEntryScanner(slide.SlidingEntryMeans(abs, size, step)))
+	log10, err := slide.ParseExpr("log10(v)")
+	if err != nil {
+		panic(err)
+	}
+	logged := log10.Column(wins, "log10")
+	err = PrintWins(logged, w)
+	if err == nil {
+		err = bedscan.Error()
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -2,18 +2,21 @@ package main
 
 import (
 	"flag"
-	"strconv"
 	"os"
 	"github.com/jgbaldwinbrown/slide/pkg"
 )
 
 func main() {
-	winsize_strptr := flag.String("w", "", "Window size for sliding window")
-	winstep_strptr := flag.String("s", "", "Window step for sliding window")
+	var win slide.WindowFlags
+	win.Register(flag.CommandLine)
+	win.RegisterLegacy(flag.CommandLine, "w", "s")
+	var wf slide.WriterFlags
+	wf.Register(flag.CommandLine)
 	flag.Parse()
-	winsize, err := strconv.ParseFloat(*winsize_strptr, 64)
+	w, err := wf.NewWriter(os.Stdout)
 	if err != nil { panic(err) }
-	winstep, err := strconv.ParseFloat(*winstep_strptr, 64)
+	winsize, winstep, err := win.Parse()
+	if err != nil { panic(err) }
+	err = slide.WriteEntries(w, slide.NewBedEntryScanner(slide.SlidingEntryMeans(slide.NewBedReaderScanner(os.Stdin), winsize, winstep)))
 	if err != nil { panic(err) }
-	slide.SlidingMeans(os.Stdin, os.Stdout, winsize, winstep)
 }
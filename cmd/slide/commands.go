@@ -0,0 +1,468 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jgbaldwinbrown/slide/pkg"
+)
+
+// stringList collects repeated string flags.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// parsePairs parses comma-separated 1-based population pairs such as
+// "1:2,3:4" into 0-based pairs.
+func parsePairs(s string) ([][2]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out [][2]int
+	for _, field := range strings.Split(s, ",") {
+		ab := strings.Split(field, ":")
+		if len(ab) != 2 {
+			return nil, fmt.Errorf("population pair %q is not a:b", field)
+		}
+		var pair [2]int
+		for i, x := range ab {
+			n, e := strconv.Atoi(x)
+			if e != nil || n < 1 {
+				return nil, fmt.Errorf("population %q is not a positive integer", x)
+			}
+			pair[i] = n - 1
+		}
+		out = append(out, pair)
+	}
+	return out, nil
+}
+
+func parseFloats(s string) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []float64
+	for _, field := range strings.Split(s, ",") {
+		f, e := strconv.ParseFloat(field, 64)
+		if e != nil {
+			return nil, e
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// runBed runs a windowing function over BED input.
+func runBed(c *common, args []string, check func() error, slider func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry) error {
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	if e := check(); e != nil {
+		return usagef("slide %s: %v", c.fs.Name(), e)
+	}
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	b := slide.NewBedReaderScanner(r)
+	out, srcs := c.windows(c.input(b), slider)
+	return c.write(out, append(srcs, b)...)
+}
+
+func aggregateCommand(name, summary string, agg slide.Aggregator) command {
+	return command{Name: name, Summary: summary, Run: func(name string, args []string) error {
+		c := newCommon(name, summary + ". Input is bedGraph: chrom, start, end, value.", true)
+		return runBed(c, args, noCheck, func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry {
+			return slide.SlidingAggregate(in, size, step, agg)
+		})
+	}}
+}
+
+// alleleFlags selects sync or VCF input for the allele count commands.
+type alleleFlags struct {
+	Vcf bool
+	Pops string
+}
+
+func (a *alleleFlags) register(c *common) {
+	c.fs.BoolVar(&a.Vcf, "vcf", false, "Input is VCF rather than sync")
+	c.fs.StringVar(&a.Pops, "pops", "", "VCF sample to population file (default: one population)")
+}
+
+type errorScanner interface {
+	slide.BedOutputScanner
+	Error() error
+}
+
+func (a *alleleFlags) scanner(r io.Reader) (errorScanner, error) {
+	if !a.Vcf {
+		return slide.NewSyncReaderScanner(r), nil
+	}
+	var pops map[string]int
+	if a.Pops != "" {
+		f, e := os.Open(a.Pops)
+		if e != nil {
+			return nil, e
+		}
+		defer f.Close()
+		if pops, _, e = slide.ReadPopMap(f); e != nil {
+			return nil, e
+		}
+	}
+	return slide.NewVcfScanner(r, pops), nil
+}
+
+// runAlleles runs a windowing function over sync or VCF input.
+func runAlleles(c *common, a *alleleFlags, args []string, check func() error, slider func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry) error {
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	if e := check(); e != nil {
+		return usagef("slide %s: %v", c.fs.Name(), e)
+	}
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	s, e := a.scanner(r)
+	if e != nil {
+		return e
+	}
+	out, srcs := c.windows(c.input(s), slider)
+	return c.write(out, append(srcs, s)...)
+}
+
+func noCheck() error {
+	return nil
+}
+
+func init() {
+	register(aggregateCommand("mean", "Mean of the values in each window", slide.MeanAggregator))
+	register(aggregateCommand("sum", "Sum of the values in each window", slide.SumAggregator))
+	register(aggregateCommand("count", "Number of entries overlapping each window", slide.CountAggregator))
+
+	register(command{Name: "agg", Summary: "Aggregate values, such as p-values, with a named aggregator", Run: runAgg})
+	register(command{Name: "gff-count", Summary: "Number of GFF features overlapping each window", Run: runGffCount})
+	register(command{Name: "gff-bp", Summary: "Base pairs covered by GFF features in each window", Run: runGffBp})
+	register(command{Name: "wig", Summary: "Mean of WIG or bedGraph values in each window", Run: runWig})
+	register(command{Name: "sync", Summary: "Coverage and minor allele frequency of sync sites in each window", Run: runSync})
+	register(command{Name: "fst", Summary: "Fst between populations in each window", Run: runFst})
+	register(command{Name: "diversity", Summary: "Theta pi, Watterson's theta and Tajima's D in each window", Run: runDiversity})
+	register(command{Name: "cmh", Summary: "Cochran-Mantel-Haenszel tests summarized in each window", Run: runCmh})
+	register(command{Name: "slopes", Summary: "Treatment minus control allele frequency slopes in each window", Run: runSlopes})
+	register(command{Name: "qvalue", Summary: "Add Benjamini-Hochberg q-values and Bonferroni p-values to windows", Run: runQValue})
+	register(command{Name: "seq", Summary: "GC content and sequence composition of FASTA windows", Run: runSeq})
+	register(command{Name: "motif", Summary: "Motif matches in FASTA windows", Run: runMotif})
+	register(command{Name: "span", Summary: "Merge values beyond thresholds into spans", Run: runSpan})
+	register(command{Name: "over", Summary: "Aggregate a value track over BED or GFF windows such as genes or peaks", Run: runOver})
+	register(command{Name: "metagene", Summary: "Average a value track over scaled features and their flanks", Run: runMetagene})
+}
+
+func runAgg(name string, args []string) error {
+	c := newCommon(name, "Aggregate the values of bedGraph input in each window with one of: " + strings.Join(slide.AggregatorNames(), ", ") + ".", true)
+	aggName := c.fs.String("f", "mean", "Aggregator name")
+	var agg slide.Aggregator
+	check := func() (e error) {
+		if agg, e = slide.ParseAggregator(*aggName); e != nil {
+			return e
+		}
+		if *aggName == "wmean" || *aggName == "covered" {
+			return c.physical("-f " + *aggName)
+		}
+		return nil
+	}
+	return runBed(c, args, check, func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry {
+		return slide.SlidingAggregate(in, size, step, agg)
+	})
+}
+
+func runGff(name, summary string, args []string, bp bool, slider func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry) error {
+	c := newCommon(name, summary + ".", true)
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	if bp {
+		if e := c.physical(name); e != nil {
+			return usagef("slide %s: %v", name, e)
+		}
+	}
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	gff := slide.NewGffScanner(r)
+	out, srcs := c.windows(c.input(gff), slider)
+	return c.write(out, append(srcs, gff)...)
+}
+
+func runGffCount(name string, args []string) error {
+	return runGff(name, commands[name].Summary, args, false, slide.SlidingGffEntryCount)
+}
+
+func runGffBp(name string, args []string) error {
+	return runGff(name, commands[name].Summary, args, true, slide.SlidingGffBpCovered)
+}
+
+func runWig(name string, args []string) error {
+	c := newCommon(name, "Mean of the values of WIG fixedStep, variableStep or bedGraph input in each window.", true)
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	wig := slide.NewWigScanner(r)
+	out, srcs := c.windows(c.input(wig), func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry {
+		return slide.SlidingAggregate(in, size, step, slide.MeanAggregator)
+	})
+	return c.write(out, append(srcs, wig)...)
+}
+
+func runSync(name string, args []string) error {
+	c := newCommon(name, "Site counts, mean coverage and mean minor allele frequency of each population of a sync file in each window.", true)
+	minCount := c.fs.Int64("min-count", 1, "Minimum minor allele count for a site to be polymorphic")
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	s := slide.NewSyncReaderScanner(r)
+	out, srcs := c.windows(c.input(s), func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry {
+		return slide.SlidingSyncStats(in, size, step, *minCount)
+	})
+	return c.write(out, append(srcs, s)...)
+}
+
+func runFst(name string, args []string) error {
+	c := newCommon(name, "Fst for pairs of populations of a sync or VCF file in each window. Val is the Fst of the first pair.", true)
+	var a alleleFlags
+	a.register(c)
+	method := c.fs.String("method", "hudson", "Estimator: hudson, wc or pool")
+	pairs := c.fs.String("pairs", "", "Comma-separated 1-based population pairs such as 1:2,1:3 (default: all pairs)")
+	poolSizes := c.fs.String("pool-sizes", "", "Comma-separated haploid pool sizes of each population, for -method pool")
+	var opts slide.FstOptions
+	c.fs.Float64Var(&opts.MinCoverage, "min-cov", 0, "Minimum coverage of each population at a site")
+
+	check := func() (e error) {
+		if opts.Method, e = slide.ParseFstMethod(*method); e != nil {
+			return e
+		}
+		if opts.Pairs, e = parsePairs(*pairs); e != nil {
+			return e
+		}
+		if opts.PoolSizes, e = parseFloats(*poolSizes); e != nil {
+			return e
+		}
+		if opts.Method == slide.FstPool && opts.PoolSizes == nil {
+			return fmt.Errorf("-method pool requires -pool-sizes")
+		}
+		return nil
+	}
+	return runAlleles(c, &a, args, check, func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry {
+		return slide.SlidingFst(in, size, step, opts)
+	})
+}
+
+func runDiversity(name string, args []string) error {
+	c := newCommon(name, "Theta pi, Watterson's theta and Tajima's D of one population of a sync or VCF file in each window.", true)
+	var a alleleFlags
+	a.register(c)
+	var opts slide.DiversityOptions
+	pop := c.fs.Int("pop", 1, "1-based population to analyse")
+	c.fs.Float64Var(&opts.PoolSize, "pool-size", 0, "Haploid pool size of at least 2 for pool-seq data (0 for called genotypes)")
+	c.fs.Float64Var(&opts.MinCoverage, "min-cov", 0, "Minimum coverage of a callable site")
+	c.fs.Float64Var(&opts.MaxCoverage, "max-cov", 0, "Maximum coverage of a callable site (0 for no limit)")
+	c.fs.Float64Var(&opts.MinCount, "min-count", 1, "Minimum count of each allele at a segregating site")
+	c.fs.BoolVar(&opts.WindowCallable, "window-callable", false, "Divide by window length, for input listing only variant sites")
+
+	check := func() error {
+		if *pop < 1 {
+			return fmt.Errorf("-pop must be at least 1")
+		}
+		opts.Pop = *pop - 1
+		if opts.WindowCallable {
+			if e := c.physical("-window-callable"); e != nil {
+				return e
+			}
+		}
+		return opts.Validate()
+	}
+	return runAlleles(c, &a, args, check, func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry {
+		return slide.SlidingDiversity(in, size, step, opts)
+	})
+}
+
+func runCmh(name string, args []string) error {
+	c := newCommon(name, "Per-site Cochran-Mantel-Haenszel tests over replicate control:treatment population pairs, summarized in each window. Val is the minimum p-value.", true)
+	var a alleleFlags
+	a.register(c)
+	var opts slide.CmhOptions
+	pairs := c.fs.String("pairs", "", "Comma-separated 1-based control:treatment population pairs, one per replicate, such as 1:2,3:4")
+	c.fs.Float64Var(&opts.MinCoverage, "min-cov", 0, "Minimum coverage of each population in a replicate")
+	c.fs.Float64Var(&opts.Threshold, "threshold", 0.05, "p-value below which sites are counted")
+
+	check := func() (e error) {
+		if opts.Pairs, e = parsePairs(*pairs); e != nil {
+			return e
+		}
+		if len(opts.Pairs) == 0 {
+			return fmt.Errorf("-pairs is required")
+		}
+		return nil
+	}
+	return runAlleles(c, &a, args, check, func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry {
+		return slide.SlidingCmh(in, size, step, opts)
+	})
+}
+
+func runSlopes(name string, args []string) error {
+	c := newCommon(name, "Per-site regressions of minor allele frequency on generation, averaged over replicates. Val is the mean treatment slope minus the mean control slope.", true)
+	var a alleleFlags
+	a.register(c)
+	var opts slide.SlopeOptions
+	design := c.fs.String("design", "", "Design file of 1-based population column, generation, treatment and replicate")
+	c.fs.StringVar(&opts.Treatment, "treatment", "", "Treatment name in the design file")
+	c.fs.StringVar(&opts.Control, "control", "", "Control name in the design file")
+	c.fs.Float64Var(&opts.MinCoverage, "min-cov", 0, "Minimum coverage of a sample used in a fit")
+
+	check := func() error {
+		if *design == "" || opts.Treatment == "" || opts.Control == "" {
+			return fmt.Errorf("-design, -treatment and -control are required")
+		}
+		f, e := os.Open(*design)
+		if e != nil {
+			return e
+		}
+		defer f.Close()
+		opts.Design, e = slide.ReadTrajectoryDesign(f)
+		return e
+	}
+	return runAlleles(c, &a, args, check, func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry {
+		return slide.SlidingSlopeDiffs(in, size, step, opts)
+	})
+}
+
+func runQValue(name string, args []string) error {
+	c := newCommon(name, "Read bedGraph p-values, such as windowed output, and add Benjamini-Hochberg q-value and Bonferroni columns computed over all entries.", false)
+	var opts slide.QValueOptions
+	c.fs.IntVar(&opts.MaxInMemory, "max-in-memory", 0, "Entries kept in memory before spilling to a temporary file (0 for no limit)")
+	c.fs.StringVar(&opts.TempDir, "tmpdir", "", "Directory for spill files")
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	b := slide.NewBedReaderScanner(r)
+	q, e := slide.CollectQValues(c.input(b), opts)
+	if e != nil {
+		return e
+	}
+	defer q.Close()
+	if e := b.Error(); e != nil {
+		return e
+	}
+	return c.write(q)
+}
+
+// runFasta runs a windowing function over FASTA input. Regions select the
+// output windows, since FASTA records cannot be filtered by position.
+func runFasta(c *common, args []string, check func() error, slider func(in slide.FaOutputScanner, size, step float64) <-chan slide.BedEntry) error {
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	if len(c.Pre) > 0 {
+		return usagef("slide %s: -pre is not supported for FASTA input", c.fs.Name())
+	}
+	if c.GeneticMap != "" {
+		return usagef("slide %s: -genetic-map is not supported for FASTA input", c.fs.Name())
+	}
+	if e := check(); e != nil {
+		return usagef("slide %s: %v", c.fs.Name(), e)
+	}
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	fa := slide.NewFaScanner(r)
+	return c.write(c.regionFilter(slide.NewBedEntryScanner(slider(fa, c.Size, c.Step))), fa)
+}
+
+func runSeq(name string, args []string) error {
+	c := newCommon(name, "GC fraction, N fraction, CpG observed/expected and GC and AT skew of FASTA windows. Val is the GC fraction.", true)
+	return runFasta(c, args, noCheck, slide.SlidingSeqStats)
+}
+
+func runMotif(name string, args []string) error {
+	c := newCommon(name, "Number of motif matches starting in each FASTA window. With -both-strands, a palindromic match is counted once.", true)
+	var iupac, regex, pwm stringList
+	c.fs.Var(&iupac, "iupac", "IUPAC motif as name=PATTERN or PATTERN (repeatable)")
+	c.fs.Var(&regex, "regex", "Regular expression motif as name=EXPR or EXPR (repeatable)")
+	c.fs.Var(&pwm, "pwm", "Position count matrix file with A, C, G and T columns (repeatable)")
+	threshold := c.fs.Float64("pwm-threshold", 0, "Minimum log2 odds score of a PWM match")
+	both := c.fs.Bool("both-strands", false, "Also search the reverse complement")
+
+	var motifs []slide.Motif
+	nameValue := func(s string) (string, string) {
+		if i := strings.Index(s, "="); i >= 0 {
+			return s[:i], s[i + 1:]
+		}
+		return s, s
+	}
+	check := func() error {
+		for _, s := range iupac {
+			m, e := slide.NewIupacMotif(nameValue(s))
+			if e != nil {
+				return e
+			}
+			motifs = append(motifs, m)
+		}
+		for _, s := range regex {
+			m, e := slide.NewRegexMotif(nameValue(s))
+			if e != nil {
+				return e
+			}
+			motifs = append(motifs, m)
+		}
+		for _, path := range pwm {
+			f, e := os.Open(path)
+			if e != nil {
+				return e
+			}
+			counts, e := slide.ReadPwmCounts(f)
+			f.Close()
+			if e != nil {
+				return e
+			}
+			motifs = append(motifs, slide.NewPwmMotif(path, counts, *threshold))
+		}
+		if len(motifs) == 0 {
+			return fmt.Errorf("at least one -iupac, -regex or -pwm motif is required")
+		}
+		return nil
+	}
+	return runFasta(c, args, check, func(in slide.FaOutputScanner, size, step float64) <-chan slide.BedEntry {
+		return slide.SlidingMotifCounts(in, motifs, *both, size, step)
+	})
+}
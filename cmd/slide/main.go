@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jgbaldwinbrown/slide/pkg"
+)
+
+// usageError marks errors in the command line, which exit with status 2.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+type command struct {
+	Name string
+	Summary string
+	Run func(name string, args []string) error
+}
+
+var commands = map[string]command{}
+
+func register(c command) {
+	commands[c.Name] = c
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: slide <command> [flags]\n\nCommands:\n")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-12s %s\n", name, commands[name].Summary)
+	}
+	fmt.Fprintf(w, "\nRun \"slide <command> -help\" for the flags of a command.\n")
+}
+
+// regionFlags collects repeated -region flags.
+type regionFlags []slide.Region
+
+func (r *regionFlags) String() string {
+	var out []string
+	for _, reg := range *r {
+		out = append(out, reg.String())
+	}
+	return strings.Join(out, " ")
+}
+
+func (r *regionFlags) Set(s string) error {
+	reg, e := slide.ParseRegion(s)
+	if e != nil {
+		return e
+	}
+	*r = append(*r, reg)
+	return nil
+}
+
+// common holds the flags shared by all subcommands.
+type common struct {
+	fs *flag.FlagSet
+	In string
+	Out string
+	Regions regionFlags
+	Window slide.WindowFlags
+	Size float64
+	Step float64
+	Writer slide.WriterFlags
+	Pre slide.Exprs
+	Post slide.Exprs
+	Annotate string
+	AnnotateTypes string
+	GeneticMap string
+	MapFormat string
+	MapChrom string
+	geneticMap *slide.GeneticMap
+	annotator *slide.Annotator
+	windowed bool
+	hasWriter bool
+}
+
+func newCommon(name string, summary string, windowed bool) *common {
+	c := newBaseCommon(name, summary, windowed)
+	if windowed {
+		c.fs.Var(&c.Pre, "pre", "Expression applied to input values before windowing, such as abs(v) or v > 2 (repeatable)")
+	}
+	c.fs.Var(&c.Post, "post", "Expression applied to output values, such as -post=-log10(v) (repeatable)")
+	c.fs.StringVar(&c.Annotate, "annotate", "", "GFF or BED file whose features overlapping and nearest to each output entry are added as columns")
+	c.fs.StringVar(&c.AnnotateTypes, "annotate-types", "", "Comma-separated GFF feature types for -annotate, each in its own column (default: all in one column)")
+	c.Writer.Register(c.fs)
+	c.hasWriter = true
+	return c
+}
+
+// newBaseCommon registers the shared flags except the output format flags,
+// for commands that write their own format.
+func newBaseCommon(name string, summary string, windowed bool) *common {
+	c := &common{fs: flag.NewFlagSet(name, flag.ContinueOnError), windowed: windowed}
+	c.fs.StringVar(&c.In, "i", "-", "Input file (- for stdin)")
+	c.fs.StringVar(&c.Out, "o", "-", "Output file (- for stdout)")
+	c.fs.Var(&c.Regions, "region", "Only use data overlapping chrom or chrom:start-end (repeatable)")
+	if windowed {
+		c.Window.Register(c.fs)
+		c.fs.StringVar(&c.GeneticMap, "genetic-map", "", "Genetic map file; -size and -step are then in cM, and windows are reported in bp with their cM boundaries")
+		c.fs.StringVar(&c.MapFormat, "map-format", "", "Genetic map format: " + strings.Join(slide.GeneticMapFormats, ", ") + " (default: plink for .map files, otherwise hapmap)")
+		c.fs.StringVar(&c.MapChrom, "map-chrom", "", "Chromosome of a hapmap file without a chromosome column")
+	}
+	c.fs.SetOutput(os.Stderr)
+	c.fs.Usage = func() {
+		fmt.Fprintf(c.fs.Output(), "usage: slide %s [flags]\n\n%s\n\nFlags:\n", name, summary)
+		c.fs.PrintDefaults()
+	}
+	return c
+}
+
+func (c *common) parse(args []string) error {
+	if e := c.fs.Parse(args); e != nil {
+		if errors.Is(e, flag.ErrHelp) {
+			return e
+		}
+		// The flag package has already printed the error and usage.
+		return usageError{}
+	}
+	if c.fs.NArg() > 0 {
+		return usagef("slide %s: unexpected arguments %v", c.fs.Name(), c.fs.Args())
+	}
+	if c.windowed {
+		var e error
+		c.Window.Genetic = c.GeneticMap != ""
+		if c.Size, c.Step, e = c.Window.Parse(); e != nil {
+			return usagef("slide %s: %v", c.fs.Name(), e)
+		}
+		if c.GeneticMap != "" {
+			if c.geneticMap, e = slide.LoadGeneticMap(c.GeneticMap, c.MapFormat, c.MapChrom); e != nil {
+				return e
+			}
+		}
+	}
+	if _, e := c.Writer.NewWriter(io.Discard); c.hasWriter && e != nil {
+		return usagef("slide %s: %v", c.fs.Name(), e)
+	}
+	// Load -annotate now, so that a bad file fails before create truncates
+	// the output.
+	if c.Annotate != "" {
+		opts := slide.DefaultAnnotateOptions()
+		if c.AnnotateTypes != "" {
+			opts.Types = strings.Split(c.AnnotateTypes, ",")
+		}
+		var e error
+		if c.annotator, e = slide.LoadAnnotator(c.Annotate, opts); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (c *common) open() (io.ReadCloser, error) {
+	if c.In == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(c.In)
+}
+
+func (c *common) regionFilter(in slide.BedOutputScanner) slide.BedOutputScanner {
+	if len(c.Regions) == 0 {
+		return in
+	}
+	return slide.NewBedEntryScanner(slide.RegionFilter(in, c.Regions))
+}
+
+// input applies the region filter and -pre expressions to in.
+func (c *common) input(in slide.BedOutputScanner) slide.BedOutputScanner {
+	return c.Pre.Apply(c.regionFilter(in))
+}
+
+// windows runs slider over in with the -size and -step windows, measured
+// along the genetic map if one was given. It also returns the scanners
+// whose errors must be checked.
+func (c *common) windows(in slide.BedOutputScanner, slider func(in slide.BedOutputScanner, size, step float64) <-chan slide.BedEntry) (slide.BedOutputScanner, []interface{ Error() error }) {
+	if c.geneticMap == nil {
+		return slide.NewBedEntryScanner(slider(in, c.Size, c.Step)), nil
+	}
+	g := slide.NewGeneticScanner(in, c.geneticMap)
+	return slide.NewBedEntryScanner(slide.GeneticWindows(g, c.Size, c.Step, slider)), []interface{ Error() error }{g}
+}
+
+// physical reports an error if -genetic-map was given, for statistics
+// that measure lengths in bp, which would otherwise be in cM.
+func (c *common) physical(what string) error {
+	if c.geneticMap != nil {
+		return fmt.Errorf("%s measures lengths in bp and cannot be used with -genetic-map", what)
+	}
+	return nil
+}
+
+// create opens the output file. The returned function closes it.
+func (c *common) create() (io.Writer, func() error, error) {
+	if c.Out == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, e := os.Create(c.Out)
+	if e != nil {
+		return nil, nil, e
+	}
+	return f, f.Close, nil
+}
+
+// write applies the -post expressions and -annotate to out, writes it to
+// the output file and then reports the first error of the input scanners
+// srcs.
+func (c *common) write(out slide.BedOutputScanner, srcs ...interface{ Error() error }) (err error) {
+	w, closer, e := c.create()
+	if e != nil {
+		return e
+	}
+	defer func() {
+		if e := closer(); err == nil {
+			err = e
+		}
+	}()
+	ew, e := c.Writer.NewWriter(w)
+	if e != nil {
+		return e
+	}
+	out = c.Post.Apply(out)
+	if c.annotator != nil {
+		out = c.annotator.Apply(out)
+	}
+	if e := slide.WriteEntries(ew, out); e != nil {
+		return e
+	}
+	for _, s := range srcs {
+		if e := s.Error(); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage(os.Stdout)
+		return
+	}
+	c, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "slide: unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	err := c.Run(name, os.Args[2:])
+	var ue usageError
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+	case errors.As(err, &ue):
+		if ue.msg != "" {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+		}
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "slide %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
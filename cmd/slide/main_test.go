@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPlinkMap = `chr1	rs1	0	1000
+chr1	rs2	1	2000
+chr1	rs3	3	4000
+`
+
+const testGff = `chr1	.	gene	1001	1500	.	+	.	ID=g1
+chr1	.	gene	2001	3000	.	+	.	ID=g2
+`
+
+func writeTestFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if e := os.WriteFile(path, []byte(data), 0644); e != nil {
+		t.Fatal(e)
+	}
+	return path
+}
+
+func TestGeneticMapLengths(t *testing.T) {
+	m := writeTestFile(t, "test.map", testPlinkMap)
+	gff := writeTestFile(t, "test.gff", testGff)
+	out := filepath.Join(t.TempDir(), "out.bed")
+	windowed := []string{"-genetic-map", m, "-size", "1", "-step", "1", "-o", out}
+
+	for _, c := range []struct {
+		name string
+		args []string
+	}{
+		{"gff-bp", []string{"-i", gff}},
+		{"diversity", []string{"-window-callable"}},
+		{"agg", []string{"-f", "wmean"}},
+		{"agg", []string{"-f", "covered"}},
+	} {
+		e := commands[c.name].Run(c.name, append(c.args, windowed...))
+		var ue usageError
+		if !errors.As(e, &ue) {
+			t.Errorf("slide %s %v with -genetic-map: got %v, want a usage error", c.name, c.args, e)
+		}
+	}
+
+	if e := commands["gff-count"].Run("gff-count", append([]string{"-i", gff}, windowed...)); e != nil {
+		t.Errorf("slide gff-count with -genetic-map: %v", e)
+	}
+	if e := commands["gff-bp"].Run("gff-bp", []string{"-i", gff, "-size", "1000", "-step", "1000", "-o", out}); e != nil {
+		t.Errorf("slide gff-bp without -genetic-map: %v", e)
+	}
+}
+
+func TestRunRegionOverride(t *testing.T) {
+	config := writeTestFile(t, "p.yaml", "input:\n  regions: [chr1]\nstages:\n  - window: {stat: mean, size: 10}\n")
+	in := writeTestFile(t, "in.bed", "chr1\t0\t5\t1\nchr2\t0\t5\t2\n")
+	out := filepath.Join(t.TempDir(), "out.bed")
+	if e := commands["run"].Run("run", []string{"-c", config, "-i", in, "-o", out, "-region", "chr2"}); e != nil {
+		t.Fatal(e)
+	}
+	got, e := os.ReadFile(out)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !strings.HasSuffix(string(got), "\nchr2\t0\t10\t2\n") || strings.Contains(string(got), "chr1") {
+		t.Errorf("slide run -region chr2 wrote %q, want only chr2", got)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jgbaldwinbrown/slide/pkg"
+)
+
+func runMetagene(name string, args []string) error {
+	c := newCommon(name, "Average a bedGraph track over features from a BED or GFF file, each scaled to -bins bins with fixed-size flanks that follow the feature's strand. Writes one line per bin with the mean, standard deviation and number of features, or with -matrix one line per feature with its bins as columns and the mean of its body bins as the value. Both inputs must be sorted by position within each chromosome. Bins are aggregated with one of: " + strings.Join(slide.AggregatorNames(), ", ") + ".", false)
+	c.fs.Var(&c.Pre, "pre", "Expression applied to track values before binning (repeatable)")
+	var features featureFlags
+	features.register(c, "features", "gene")
+	opts := slide.DefaultMetageneOptions()
+	c.fs.IntVar(&opts.Bins, "bins", opts.Bins, "Bins per feature body")
+	c.fs.Float64Var(&opts.Upstream, "up", 0, "Upstream flank in bp")
+	c.fs.Float64Var(&opts.Downstream, "down", 0, "Downstream flank in bp")
+	c.fs.IntVar(&opts.FlankBins, "flank-bins", 10, "Bins per flank")
+	aggName := c.fs.String("f", "wmean", "Aggregator name")
+	matrix := c.fs.Bool("matrix", false, "Write one line per feature instead of the average profile")
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	var e error
+	if opts.Agg, e = slide.ParseAggregator(*aggName); e != nil {
+		return usagef("slide %s: %v", name, e)
+	}
+	if e := opts.Validate(); e != nil {
+		return usagef("slide %s: %v", name, e)
+	}
+	if e := features.check("features"); e != nil {
+		return usagef("slide %s: %v", name, e)
+	}
+	if !*matrix && (c.Writer.Format != "tsv" || len(c.Post) > 0 || c.Annotate != "") {
+		return usagef("slide %s: -format, -post and -annotate apply only with -matrix", name)
+	}
+
+	fs, fsrc, ff, e := features.open(c)
+	if e != nil {
+		return e
+	}
+	defer ff.Close()
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	track := slide.NewBedReaderScanner(r)
+	sortedFeatures := slide.NewSortChecker(fs)
+	sortedTrack := slide.NewSortChecker(c.input(track))
+	rows := slide.MetageneMatrix(sortedFeatures, sortedTrack, opts)
+	srcs := []interface{ Error() error }{fsrc, track, sortedFeatures, sortedTrack}
+	if *matrix {
+		return c.write(slide.NewBedEntryScanner(rows), srcs...)
+	}
+
+	p := slide.NewMetageneProfile(opts)
+	for b := range rows {
+		if e := p.Add(b); e != nil {
+			return e
+		}
+	}
+	for _, s := range srcs {
+		if e := s.Error(); e != nil {
+			return e
+		}
+	}
+	w, closer, e := c.create()
+	if e != nil {
+		return e
+	}
+	if e := p.WriteTsv(w, c.Writer.Options()); e != nil {
+		closer()
+		return e
+	}
+	return closer()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jgbaldwinbrown/slide/pkg"
+)
+
+// featureFlags selects a BED or GFF file of features, such as genes or
+// peaks, used as windows.
+type featureFlags struct {
+	Path string
+	Gff bool
+	Types string
+	defaultTypes string
+}
+
+// register adds the feature flags to c. GFF features default to types,
+// or to all types if it is empty.
+func (f *featureFlags) register(c *common, name string, types string) {
+	f.defaultTypes = types
+	c.fs.StringVar(&f.Path, name, "", "BED or GFF file of " + name)
+	c.fs.BoolVar(&f.Gff, "gff", false, "-" + name + " is GFF (default: when it ends in .gff or .gff3)")
+	if types == "" {
+		c.fs.StringVar(&f.Types, "type", "", "Comma-separated GFF feature types to use (default: all)")
+	} else {
+		c.fs.StringVar(&f.Types, "type", types, "Comma-separated GFF feature types to use, or -type= for all")
+	}
+}
+
+func (f *featureFlags) isGff() bool {
+	return f.Gff || strings.HasSuffix(f.Path, ".gff") || strings.HasSuffix(f.Path, ".gff3")
+}
+
+func (f *featureFlags) check(name string) error {
+	if f.Path == "" {
+		return fmt.Errorf("-%s is required", name)
+	}
+	if f.Types != f.defaultTypes && !f.isGff() {
+		return fmt.Errorf("-type requires GFF features")
+	}
+	return nil
+}
+
+// open returns the features, filtered by c's regions, and the scanner
+// reading them.
+func (f *featureFlags) open(c *common) (slide.BedOutputScanner, errorScanner, io.Closer, error) {
+	r, e := os.Open(f.Path)
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	if f.isGff() {
+		g := slide.NewGffScanner(r)
+		var keep []string
+		if f.Types != "" {
+			keep = strings.Split(f.Types, ",")
+		}
+		return c.regionFilter(slide.GffFeatures(g, keep)), g, r, nil
+	}
+	b := slide.NewBedIntervalScanner(r)
+	return c.regionFilter(b), b, r, nil
+}
+
+func runOver(name string, args []string) error {
+	c := newCommon(name, "Aggregate the values of a bedGraph track over windows read from a BED or GFF file, such as genes or peaks, with one of: " + strings.Join(slide.AggregatorNames(), ", ") + ". Each window keeps its BED columns or GFF fields. Both inputs must be sorted by position within each chromosome unless -index is given.", false)
+	c.fs.Var(&c.Pre, "pre", "Expression applied to track values before aggregating (repeatable)")
+	var features featureFlags
+	features.register(c, "windows", "")
+	aggName := c.fs.String("f", "mean", "Aggregator name")
+	index := c.fs.Bool("index", false, "Load the track into memory, so that neither input need be sorted")
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	agg, e := slide.ParseAggregator(*aggName)
+	if e != nil {
+		return usagef("slide %s: %v", name, e)
+	}
+	if e := features.check("windows"); e != nil {
+		return usagef("slide %s: %v", name, e)
+	}
+
+	windows, wsrc, wf, e := features.open(c)
+	if e != nil {
+		return e
+	}
+	defer wf.Close()
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	track := slide.NewBedReaderScanner(r)
+	if *index {
+		x, e := slide.NewIntervalIndex(c.input(track))
+		if e != nil {
+			return e
+		}
+		if e := track.Error(); e != nil {
+			return e
+		}
+		return c.write(slide.NewBedEntryScanner(x.Aggregate(windows, agg)), wsrc)
+	}
+	sortedWindows := slide.NewSortChecker(windows)
+	sortedTrack := slide.NewSortChecker(c.input(track))
+	return c.write(slide.NewBedEntryScanner(slide.AggregateOver(sortedWindows, sortedTrack, agg)), wsrc, track, sortedWindows, sortedTrack)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/jgbaldwinbrown/slide/pkg"
+)
+
+func init() {
+	register(command{Name: "run", Summary: "Run a YAML or JSON pipeline file", Run: runPipeline})
+}
+
+func runPipeline(name string, args []string) (err error) {
+	c := newBaseCommon(name, "Run the stages of a YAML or JSON pipeline file (see the slide.Pipeline documentation). -i, -o and -region override the input path, output path and regions of the file, and the resolved pipeline is written as header comments.", false)
+	config := c.fs.String("c", "", "Pipeline file")
+	c.In, c.Out = "", ""
+	c.fs.Lookup("i").DefValue = ""
+	c.fs.Lookup("o").DefValue = ""
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	if *config == "" {
+		return usagef("slide %s: -c is required", name)
+	}
+	p, e := slide.LoadPipelineFile(*config)
+	if e != nil {
+		return e
+	}
+
+	if c.In != "" {
+		p.Input.Path = c.In
+	}
+	if c.Out != "" {
+		p.Output.Path = c.Out
+	}
+	if len(c.Regions) > 0 {
+		p.Input.Regions = nil
+	}
+	for _, r := range c.Regions {
+		p.Input.Regions = append(p.Input.Regions, r.String())
+	}
+	if e := p.Resolve(); e != nil {
+		return e
+	}
+
+	c.In, c.Out = p.Input.Path, p.Output.Path
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	w, closer, e := c.create()
+	if e != nil {
+		return e
+	}
+	defer func() {
+		if e := closer(); err == nil {
+			err = e
+		}
+	}()
+	return p.Run(r, w)
+}
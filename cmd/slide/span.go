@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/jgbaldwinbrown/fasttsv"
+	"github.com/jgbaldwinbrown/slide/pkg"
+)
+
+// readSites reads chrom, 1-based position and value columns after a header
+// line, as bedspan does, and sorts them by position. Lines with
+// unparseable positions or values are skipped.
+func readSites(r io.Reader) ([]slide.BedEntry, error) {
+	var out []slide.BedEntry
+	s := fasttsv.NewScanner(r)
+	s.Scan()
+	for s.Scan() {
+		line := s.Line()
+		if len(line) < 3 {
+			return nil, fmt.Errorf("line %v has %v fields, want at least 3", line, len(line))
+		}
+		val, e := strconv.ParseFloat(line[2], 64)
+		if e != nil {
+			continue
+		}
+		pos, e := strconv.Atoi(line[1])
+		if e != nil {
+			continue
+		}
+		out = append(out, slide.BedEntry{Chrom: line[0], Left: float64(pos - 1), Right: float64(pos), Val: val})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Chrom != out[j].Chrom {
+			return out[i].Chrom < out[j].Chrom
+		}
+		return out[i].Left < out[j].Left
+	})
+	return out, nil
+}
+
+func runSpan(name string, args []string) error {
+	c := newCommon(name, "Merge entries whose value is >= -high or <= -low into spans, reporting the peak value, mean hit value and hit count of each. Input is bedGraph sorted by position within each chromosome, such as windowed output, or with -sites a header line followed by chrom, 1-based position and value columns.", false)
+	c.fs.Var(&c.Pre, "pre", "Expression applied to input values before calling spans (repeatable)")
+	opts := slide.DefaultSpanOptions()
+	c.fs.Float64Var(&opts.High, "high", opts.High, "Values >= this are hits")
+	c.fs.Float64Var(&opts.Low, "low", opts.Low, "Values <= this are hits")
+	maxGap := c.fs.Float64("max-gap", 0, "Merge hits at most this many bp apart (-1 merges all hits on a chromosome)")
+	c.fs.IntVar(&opts.MinHits, "min-hits", 0, "Drop spans with fewer hits")
+	c.fs.Float64Var(&opts.MinLength, "min-length", 0, "Drop spans shorter than this many bp, before extension")
+	c.fs.Float64Var(&opts.LeftExtend, "left", 0, "Extend spans left by this many bp")
+	c.fs.Float64Var(&opts.RightExtend, "right", 0, "Extend spans right by this many bp")
+	sites := c.fs.Bool("sites", false, "Input is a header line followed by chrom, position and value columns")
+	if e := c.parse(args); e != nil {
+		return e
+	}
+	if math.IsInf(opts.High, 1) && math.IsInf(opts.Low, -1) {
+		return usagef("slide %s: at least one of -high and -low is required", name)
+	}
+	opts.MaxGap = *maxGap
+	if opts.MaxGap < 0 {
+		opts.MaxGap = math.Inf(1)
+	}
+
+	r, e := c.open()
+	if e != nil {
+		return e
+	}
+	defer r.Close()
+	if *sites {
+		entries, e := readSites(r)
+		if e != nil {
+			return e
+		}
+		return c.write(slide.NewBedEntryScanner(slide.CallSpans(c.input(slide.NewBedSliceScanner(entries)), opts)))
+	}
+	b := slide.NewBedReaderScanner(r)
+	return c.write(slide.NewBedEntryScanner(slide.CallSpans(c.input(b), opts)), b)
+}
@@ -7,10 +7,21 @@ import (
 )
 
 func main() {
-	sizep := flag.Int("s", 1, "Window size")
-	stepp := flag.Int("t", 1, "Window step")
+	win := slide.WindowFlags{Size: "1", Step: "1"}
+	win.Register(flag.CommandLine)
+	win.RegisterLegacy(flag.CommandLine, "s", "t")
+	var wf slide.WriterFlags
+	wf.Register(flag.CommandLine)
 	flag.Parse()
 
-	e := slide.SlidingGffBpCoveredFull(os.Stdin, os.Stdout, float64(*sizep), float64(*stepp))
+	size, step, e := win.Parse()
+	if e != nil { panic(e) }
+
+	w, e := wf.NewWriter(os.Stdout)
+	if e != nil { panic(e) }
+
+	gff := slide.NewGffScanner(os.Stdin)
+	e = slide.WriteEntries(w, slide.NewBedEntryScanner(slide.SlidingGffBpCovered(gff, size, step)))
+	if e == nil { e = gff.Error() }
 	if e != nil { panic(e) }
 }
@@ -5,4 +5,5 @@ go 1.18
 require (
 	github.com/jgbaldwinbrown/fasttsv v0.1.1
 	github.com/montanaflynn/stats v0.6.6
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -2,13 +2,15 @@
 set -e
 
 (cd cmd && (
-	ls *.go | while read i ; do
+	ls *.go | grep -v _test.go | while read i ; do
 		go build $i
 	done
 ))
 
+(cd cmd/slide && go build .)
+
 (cd scripts && (
-	ls *.go | while read i ; do
+	ls *.go | grep -v _test.go | while read i ; do
 		go build $i
 	done
 ))
@@ -19,3 +21,4 @@ cp ./scripts/bedspan ~/mybin
 cp ./cmd/lib_fst_sliding_window ~/mybin
 cp ./cmd/slide_gff_entry_count ~/mybin
 cp ./cmd/slide_gff_bp_covered ~/mybin
+cp ./cmd/slide/slide ~/mybin
@@ -0,0 +1,261 @@
+package slide
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An Aggregator reduces the entries overlapping a window to a single value.
+// win carries the window coordinates, and the Other of windows read from a
+// file; its Val is not meaningful.
+type Aggregator func(win BedEntry, items []BedEntry) float64
+
+// SlidingAggregate reports agg for each window on the Slider grid.
+func SlidingAggregate(in BedOutputScanner, size float64, step float64, agg Aggregator) <-chan BedEntry {
+	s := NewSlider(in, size, step)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for s.Step() {
+			win := BedEntry{Chrom: s.Chrom, Left: s.Left, Right: s.Right}
+			win.Val = agg(win, s.Entries())
+			out <- win
+		}
+		close(out)
+	}()
+	return out
+}
+
+// finiteVals returns the non-NaN values of items.
+func finiteVals(items []BedEntry) []float64 {
+	out := make([]float64, 0, len(items))
+	for _, b := range items {
+		if !math.IsNaN(b.Val) {
+			out = append(out, b.Val)
+		}
+	}
+	return out
+}
+
+// MeanAggregator, SumAggregator, MinAggregator and MaxAggregator ignore
+// NaN values. All but SumAggregator give NaN for windows without values.
+func MeanAggregator(win BedEntry, items []BedEntry) float64 {
+	vals := finiteVals(items)
+	sum := 0.0
+	for _, v := range vals {
+		sum += v
+	}
+	return ratio(sum, float64(len(vals)))
+}
+
+func SumAggregator(win BedEntry, items []BedEntry) float64 {
+	sum := 0.0
+	for _, v := range finiteVals(items) {
+		sum += v
+	}
+	return sum
+}
+
+func CountAggregator(win BedEntry, items []BedEntry) float64 {
+	return float64(len(items))
+}
+
+func MinAggregator(win BedEntry, items []BedEntry) float64 {
+	out := math.NaN()
+	for _, v := range finiteVals(items) {
+		if math.IsNaN(out) || v < out {
+			out = v
+		}
+	}
+	return out
+}
+
+func MaxAggregator(win BedEntry, items []BedEntry) float64 {
+	out := math.NaN()
+	for _, v := range finiteVals(items) {
+		if math.IsNaN(out) || v > out {
+			out = v
+		}
+	}
+	return out
+}
+
+// overlapBp returns the number of bases of b inside win.
+func overlapBp(win, b BedEntry) float64 {
+	return math.Max(0, math.Min(win.Right, b.Right) - math.Max(win.Left, b.Left))
+}
+
+// OverlapMeanAggregator returns the mean value weighted by the bases of
+// each entry inside the window, as for a bedGraph track of per-base values.
+func OverlapMeanAggregator(win BedEntry, items []BedEntry) float64 {
+	var sum, bp float64
+	for _, b := range items {
+		if math.IsNaN(b.Val) {
+			continue
+		}
+		w := overlapBp(win, b)
+		sum += w * b.Val
+		bp += w
+	}
+	return ratio(sum, bp)
+}
+
+// CoveredAggregator returns the number of bases of the window covered by
+// at least one entry.
+func CoveredAggregator(win BedEntry, items []BedEntry) float64 {
+	sorted := append([]BedEntry(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Left < sorted[j].Left })
+	covered := 0.0
+	right := win.Left
+	for _, b := range sorted {
+		left := math.Max(b.Left, right)
+		end := math.Min(b.Right, win.Right)
+		if end > left {
+			covered += end - left
+			right = end
+		}
+	}
+	return covered
+}
+
+// The p-value aggregators below treat Val as a p-value and skip NaN.
+
+// FisherAggregator combines the window's p-values with Fisher's method.
+func FisherAggregator(win BedEntry, items []BedEntry) float64 {
+	return FisherCombined(finiteVals(items))
+}
+
+// normalQuantileUpper returns z such that P(Z > z) = p.
+func normalQuantileUpper(p float64) float64 {
+	return math.Sqrt2 * math.Erfcinv(2 * p)
+}
+
+func normalSurvival(z float64) float64 {
+	return 0.5 * math.Erfc(z / math.Sqrt2)
+}
+
+// StoufferAggregator combines one-sided p-values with Stouffer's Z.
+func StoufferAggregator(win BedEntry, items []BedEntry) float64 {
+	return WeightedStouffer(func(BedEntry) float64 { return 1 })(win, items)
+}
+
+// StoufferEpsilon bounds the p-values combined by Stouffer's Z to
+// [StoufferEpsilon, 1 - StoufferEpsilon], so that p-values of 0 and 1 give
+// finite z-scores instead of infinities that cancel to NaN. Smaller
+// p-values are beyond the precision of math.Erfcinv anyway.
+const StoufferEpsilon = 1e-15
+
+// WeightedStouffer combines one-sided p-values with Stouffer's weighted Z,
+// sum(w z) / sqrt(sum(w^2)). Entries with a NaN or zero weight are skipped.
+func WeightedStouffer(weight func(BedEntry) float64) Aggregator {
+	return func(win BedEntry, items []BedEntry) float64 {
+		var sumWZ, sumW2 float64
+		for _, b := range items {
+			w := weight(b)
+			if math.IsNaN(b.Val) || math.IsNaN(w) || w == 0 {
+				continue
+			}
+			p := math.Min(math.Max(b.Val, StoufferEpsilon), 1 - StoufferEpsilon)
+			sumWZ += w * normalQuantileUpper(p)
+			sumW2 += w * w
+		}
+		if sumW2 == 0 {
+			return math.NaN()
+		}
+		return normalSurvival(sumWZ / math.Sqrt(sumW2))
+	}
+}
+
+// OtherWeight uses a float64 Other, as written to the "other" column, as
+// the weight of an entry.
+func OtherWeight(b BedEntry) float64 {
+	if w, ok := b.Other.(float64); ok {
+		return w
+	}
+	return math.NaN()
+}
+
+// HarmonicMeanPAggregator returns the unweighted harmonic mean p-value of
+// Wilson (2019). It is not itself a calibrated p-value for small windows
+// but is robust to dependence between tests.
+func HarmonicMeanPAggregator(win BedEntry, items []BedEntry) float64 {
+	vals := finiteVals(items)
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, p := range vals {
+		sum += 1 / p
+	}
+	return float64(len(vals)) / sum
+}
+
+// SidakAggregator returns the minimum p-value of the window with Šidák's
+// correction for the number of tests, 1 - (1 - min)^n.
+func SidakAggregator(win BedEntry, items []BedEntry) float64 {
+	vals := finiteVals(items)
+	min := MinAggregator(win, items)
+	if math.IsNaN(min) {
+		return min
+	}
+	return -math.Expm1(float64(len(vals)) * math.Log1p(-min))
+}
+
+// CountBelow counts the p-values below threshold.
+func CountBelow(threshold float64) Aggregator {
+	return func(win BedEntry, items []BedEntry) float64 {
+		n := 0.0
+		for _, b := range items {
+			if b.Val < threshold {
+				n++
+			}
+		}
+		return n
+	}
+}
+
+// Aggregators maps names to aggregators without parameters.
+var Aggregators = map[string]Aggregator {
+	"mean": MeanAggregator,
+	"sum": SumAggregator,
+	"count": CountAggregator,
+	"min": MinAggregator,
+	"max": MaxAggregator,
+	"wmean": OverlapMeanAggregator,
+	"covered": CoveredAggregator,
+	"fisher": FisherAggregator,
+	"stouffer": StoufferAggregator,
+	"stouffer_weighted": WeightedStouffer(OtherWeight),
+	"hmp": HarmonicMeanPAggregator,
+	"sidak": SidakAggregator,
+}
+
+// AggregatorNames lists the names accepted by ParseAggregator.
+func AggregatorNames() []string {
+	var out []string
+	for name := range Aggregators {
+		out = append(out, name)
+	}
+	out = append(out, "below:<threshold>")
+	sort.Strings(out)
+	return out
+}
+
+// ParseAggregator looks up an aggregator by name. "below:0.05" counts
+// p-values below 0.05.
+func ParseAggregator(name string) (Aggregator, error) {
+	if agg, ok := Aggregators[name]; ok {
+		return agg, nil
+	}
+	if strings.HasPrefix(name, "below:") {
+		t, e := strconv.ParseFloat(strings.TrimPrefix(name, "below:"), 64)
+		if e != nil {
+			return nil, fmt.Errorf("ParseAggregator: %w", e)
+		}
+		return CountBelow(t), nil
+	}
+	return nil, fmt.Errorf("ParseAggregator: unknown aggregator %q; want one of %v", name, strings.Join(AggregatorNames(), ", "))
+}
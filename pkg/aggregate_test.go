@@ -0,0 +1,60 @@
+package slide
+
+import (
+	"math"
+	"testing"
+)
+
+func pvals(ps ...float64) []BedEntry {
+	var out []BedEntry
+	for i, p := range ps {
+		out = append(out, BedEntry{Chrom: "chr1", Left: float64(i), Right: float64(i + 1), Val: p})
+	}
+	return out
+}
+
+func TestPValueAggregators(t *testing.T) {
+	var win BedEntry
+	items := pvals(0.01, 0.5, math.NaN(), 0.04)
+
+	if p := StoufferAggregator(win, pvals(0.5, 0.5)); math.Abs(p - 0.5) > 1e-12 {
+		t.Errorf("Stouffer of 0.5s %v != 0.5", p)
+	}
+	if p := StoufferAggregator(win, pvals(0.05)); math.Abs(p - 0.05) > 1e-12 {
+		t.Errorf("Stouffer of one p %v != 0.05", p)
+	}
+	if p := StoufferAggregator(win, pvals(0, 1)); math.Abs(p - 0.5) > 1e-12 {
+		t.Errorf("Stouffer of 0 and 1 %v != 0.5", p)
+	}
+	if p := StoufferAggregator(win, pvals(0, 0.5)); !(p > 0 && p < 1e-7) {
+		t.Errorf("Stouffer of 0 and 0.5 %v", p)
+	}
+	hmp := 3 / (1 / 0.01 + 1 / 0.5 + 1 / 0.04)
+	if p := HarmonicMeanPAggregator(win, items); math.Abs(p - hmp) > 1e-12 {
+		t.Errorf("harmonic mean p %v != %v", p, hmp)
+	}
+	sidak := 1 - math.Pow(0.99, 3)
+	if p := SidakAggregator(win, items); math.Abs(p - sidak) > 1e-12 {
+		t.Errorf("Sidak %v != %v", p, sidak)
+	}
+	below, err := ParseAggregator("below:0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := below(win, items); n != 2 {
+		t.Errorf("count below 0.05 %v != 2", n)
+	}
+	if m := MeanAggregator(win, nil); !math.IsNaN(m) {
+		t.Errorf("mean of empty window %v is not NaN", m)
+	}
+}
+
+func TestSlidingAggregate(t *testing.T) {
+	var out []BedEntry
+	for b := range SlidingAggregate(NewBedSliceScanner(pvals(0.1, 0.2, 0.3)), 2, 2, Aggregators["max"]) {
+		out = append(out, b)
+	}
+	if len(out) != 2 || out[0].Val != 0.2 || out[1].Val != 0.3 {
+		t.Errorf("windows %v", out)
+	}
+}
@@ -0,0 +1,161 @@
+package slide
+
+import (
+	"math"
+	"os"
+	"strings"
+)
+
+type AnnotateOptions struct {
+	// Types lists the GFF feature types reported, each in a column of its
+	// own, and used for the nearest feature. If empty, all features are
+	// used and reported in one "features" column.
+	Types []string
+	// Labels are the GFF attributes tried in turn to name a feature.
+	// Features without any are named by their coordinates.
+	Labels []string
+}
+
+func DefaultAnnotateOptions() AnnotateOptions {
+	return AnnotateOptions{Labels: []string{"Name", "ID"}}
+}
+
+// AnnotationFields lists the features overlapping an entry and the feature
+// nearest to it. Distance is 0 if the nearest feature overlaps or touches
+// the entry and negative if it is upstream, in genome coordinates. Inner is
+// the entry's original Other, whose columns are written first.
+type AnnotationFields struct {
+	Types []string
+	Overlaps [][]string
+	Nearest string
+	Distance float64
+	Inner interface{}
+}
+
+func (f AnnotationFields) Columns() []string {
+	names, _ := ExtraColumns(BedEntry{Other: f.Inner})
+	return append(append(names, f.Types...), "nearest", "nearest_distance")
+}
+
+func (f AnnotationFields) Values() []interface{} {
+	_, vals := ExtraColumns(BedEntry{Other: f.Inner})
+	for _, o := range f.Overlaps {
+		vals = append(vals, o)
+	}
+	return append(vals, f.Nearest, f.Distance)
+}
+
+// Annotator attaches the GFF or BED features overlapping and nearest to
+// each entry, holding the features in an IntervalIndex.
+type Annotator struct {
+	Opts AnnotateOptions
+	columns []string
+	index *IntervalIndex
+}
+
+// NewAnnotator reads all of features, which need not be sorted. BED
+// features are used only if opts.Types is empty, and are named by their
+// name column.
+func NewAnnotator(features BedOutputScanner, opts AnnotateOptions) (*Annotator, error) {
+	keep := map[string]bool{}
+	for _, t := range opts.Types {
+		keep[t] = true
+	}
+	in := FilterEntries(features, func(b BedEntry) bool {
+		g, ok := b.Other.(GffFields)
+		if ok && g.IsComment {
+			return false
+		}
+		return len(keep) == 0 || (ok && keep[g.Type])
+	})
+	index, e := NewIntervalIndex(in)
+	if e == nil {
+		e = scanErr(features)
+	}
+	if e != nil {
+		return nil, handle("NewAnnotator: %w")(e)
+	}
+	a := &Annotator{Opts: opts, columns: opts.Types, index: index}
+	if len(a.columns) == 0 {
+		a.columns = []string{"features"}
+	}
+	return a, nil
+}
+
+// LoadAnnotator reads features from a GFF file, if path ends in .gff or
+// .gff3, or else a BED file.
+func LoadAnnotator(path string, opts AnnotateOptions) (*Annotator, error) {
+	h := handle("LoadAnnotator: %w")
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, h(e)
+	}
+	defer f.Close()
+
+	var s BedOutputScanner
+	if strings.HasSuffix(path, ".gff") || strings.HasSuffix(path, ".gff3") {
+		s = NewGffScanner(f)
+	} else {
+		s = NewBedIntervalScanner(f)
+	}
+	a, e := NewAnnotator(s, opts)
+	if e != nil {
+		return nil, h(e)
+	}
+	return a, nil
+}
+
+func (a *Annotator) label(b BedEntry) string {
+	switch o := b.Other.(type) {
+	case GffFields:
+		for _, name := range a.Opts.Labels {
+			if v, ok := o.Attributes[name]; ok && v != "" {
+				return v
+			}
+		}
+	case BedColumns:
+		if len(o) > 0 && o[0] != "" && o[0] != "." {
+			return o[0]
+		}
+	}
+	return Region{Chrom: b.Chrom, Start: b.Left, End: b.Right}.String()
+}
+
+// Annotate returns b with AnnotationFields in Other.
+func (a *Annotator) Annotate(b BedEntry) BedEntry {
+	r := Region{Chrom: b.Chrom, Start: b.Left, End: b.Right}
+	f := AnnotationFields{Types: a.columns, Overlaps: make([][]string, len(a.columns)), Inner: b.Other}
+	seen := make([]map[string]bool, len(a.columns))
+	for _, feat := range a.index.Overlaps(r) {
+		col := 0
+		if len(a.Opts.Types) > 0 {
+			t := feat.Other.(GffFields).Type
+			for col = range a.columns {
+				if a.columns[col] == t {
+					break
+				}
+			}
+		}
+		name := a.label(feat)
+		if seen[col] == nil {
+			seen[col] = map[string]bool{}
+		}
+		if !seen[col][name] {
+			seen[col][name] = true
+			f.Overlaps[col] = append(f.Overlaps[col], name)
+		}
+	}
+
+	if near, d, ok := a.index.Nearest(r); ok {
+		f.Nearest, f.Distance = a.label(near), d
+	} else {
+		f.Distance = math.NaN()
+	}
+	b.Other = f
+	return b
+}
+
+// Apply annotates each entry of in.
+func (a *Annotator) Apply(in BedOutputScanner) *BedEntryScanner {
+	return MapEntries(in, a.Annotate)
+}
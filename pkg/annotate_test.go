@@ -0,0 +1,64 @@
+package slide
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const annotateGff = `##gff-version 3
+chr1	x	gene	101	200	.	+	.	ID=g1;Name=alpha
+chr1	x	mRNA	101	200	.	+	.	ID=t1;Parent=g1
+chr1	x	gene	501	600	.	-	.	ID=g2
+chr2	x	gene	1	50	.	+	.	ID=g3;Name=gamma
+`
+
+func TestAnnotator(t *testing.T) {
+	opts := DefaultAnnotateOptions()
+	opts.Types = []string{"gene", "mRNA"}
+	s := NewGffScanner(strings.NewReader(annotateGff))
+	a, err := NewAnnotator(s, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := s.Error(); e != nil {
+		t.Fatal(e)
+	}
+
+	in := []BedEntry{
+		{Chrom: "chr1", Left: 150, Right: 160, Other: 2.0},
+		{Chrom: "chr1", Left: 300, Right: 400},
+		{Chrom: "chr3", Left: 0, Right: 10},
+	}
+	var got []AnnotationFields
+	for _, b := range in {
+		got = append(got, a.Annotate(b).Other.(AnnotationFields))
+	}
+
+	if !reflect.DeepEqual(got[0].Overlaps, [][]string{{"alpha"}, {"t1"}}) || got[0].Nearest != "alpha" || got[0].Distance != 0 {
+		t.Errorf("entry 0: %+v", got[0])
+	}
+	names, vals := ExtraColumns(BedEntry{Other: got[0]})
+	if !reflect.DeepEqual(names, []string{"other", "gene", "mRNA", "nearest", "nearest_distance"}) || vals[0] != 2.0 {
+		t.Errorf("columns %v, values %v", names, vals)
+	}
+	if len(got[1].Overlaps[0]) != 0 || got[1].Nearest != "alpha" || got[1].Distance != -100 {
+		t.Errorf("entry 1: %+v", got[1])
+	}
+	if got[2].Nearest != "" || !math.IsNaN(got[2].Distance) {
+		t.Errorf("entry 2: %+v", got[2])
+	}
+}
+
+func TestAnnotatorBed(t *testing.T) {
+	s := NewBedIntervalScanner(strings.NewReader("chr1\t0\t10\tpeak1\nchr1\t5\t20\t.\n"))
+	a, err := NewAnnotator(s, DefaultAnnotateOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := a.Annotate(BedEntry{Chrom: "chr1", Left: 8, Right: 9}).Other.(AnnotationFields)
+	if !reflect.DeepEqual(f.Overlaps, [][]string{{"peak1", "chr1:6-20"}}) {
+		t.Errorf("got %+v", f)
+	}
+}
@@ -0,0 +1,156 @@
+package slide
+
+import (
+	"io"
+	"math"
+)
+
+type CmhOptions struct {
+	// Pairs lists the 0-based control and treatment populations of each
+	// replicate.
+	Pairs [][2]int
+	// MinCoverage is the minimum major plus minor allele count of each
+	// population in a replicate for the replicate to be used.
+	MinCoverage float64
+	// Threshold is the p-value below which a site is counted as
+	// significant in a window.
+	Threshold float64
+}
+
+type CmhFields struct {
+	Stat float64
+	P float64
+	Replicates int
+}
+
+func (f CmhFields) Columns() []string {
+	return []string{"cmh_stat", "replicates"}
+}
+
+func (f CmhFields) Values() []interface{} {
+	return []interface{}{f.Stat, f.Replicates}
+}
+
+// CmhTest runs a Cochran–Mantel–Haenszel test on the 2x2 tables of
+// population by site-wide major and minor allele, one table per replicate
+// pair, using the continuity-corrected statistic.
+func CmhTest(f PopAlleleCounts, opts CmhOptions) CmhFields {
+	var sumA, sumE, sumV float64
+	out := CmhFields{Stat: math.NaN(), P: math.NaN()}
+	for _, p := range opts.Pairs {
+		if p[0] >= f.NPops() || p[1] >= f.NPops() {
+			continue
+		}
+		a, b := f.MajorMinorCounts(p[0])
+		c, d := f.MajorMinorCounts(p[1])
+		n1, n2 := a + b, c + d
+		if n1 == 0 || n2 == 0 || n1 < opts.MinCoverage || n2 < opts.MinCoverage {
+			continue
+		}
+		m1, m2 := a + c, b + d
+		n := n1 + n2
+		sumA += a
+		sumE += n1 * m1 / n
+		sumV += n1 * n2 * m1 * m2 / (n * n * (n - 1))
+		out.Replicates++
+	}
+	if out.Replicates == 0 || sumV == 0 {
+		return out
+	}
+	dev := math.Max(math.Abs(sumA - sumE) - 0.5, 0)
+	out.Stat = dev * dev / sumV
+	out.P = ChiSquareSurvival(out.Stat, 1)
+	return out
+}
+
+// SiteCmh emits each site of in with its CMH p-value in Val and CmhFields
+// in Other.
+func SiteCmh(in BedOutputScanner, opts CmhOptions) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for in.Scan() {
+			b := in.Entry()
+			f, ok := b.Other.(PopAlleleCounts)
+			if !ok {
+				continue
+			}
+			res := CmhTest(f, opts)
+			b.Val = res.P
+			b.Other = res
+			out <- b
+		}
+		close(out)
+	}()
+	return out
+}
+
+type CmhWindow struct {
+	Sites int
+	MinP float64
+	BelowThreshold int
+	FisherP float64
+}
+
+func (w CmhWindow) Columns() []string {
+	return []string{"sites", "min_p", "below_threshold", "fisher_p"}
+}
+
+func (w CmhWindow) Values() []interface{} {
+	return []interface{}{w.Sites, w.MinP, w.BelowThreshold, w.FisherP}
+}
+
+// WindowCmh summarizes the p-values in Val of items, as produced by
+// SiteCmh. Sites with NaN p-values are ignored.
+func WindowCmh(items []BedEntry, threshold float64) CmhWindow {
+	out := CmhWindow{MinP: math.NaN()}
+	var ps []float64
+	for _, b := range items {
+		if math.IsNaN(b.Val) {
+			continue
+		}
+		ps = append(ps, b.Val)
+		out.Sites++
+		if math.IsNaN(out.MinP) || b.Val < out.MinP {
+			out.MinP = b.Val
+		}
+		if b.Val < threshold {
+			out.BelowThreshold++
+		}
+	}
+	out.FisherP = FisherCombined(ps)
+	return out
+}
+
+// SlidingCmh reports WindowCmh of per-site CMH tests for each window, with
+// the minimum p-value in Val.
+func SlidingCmh(in BedOutputScanner, size float64, step float64, opts CmhOptions) <-chan BedEntry {
+	mustWindow(size, step)
+	s := NewSlider(NewBedEntryScanner(SiteCmh(in, opts)), size, step)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for s.Step() {
+			w := WindowCmh(s.Entries(), opts.Threshold)
+			out <- BedEntry{Chrom: s.Chrom, Left: s.Left, Right: s.Right, Val: w.MinP, Other: w}
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SlidingSyncCmhFull(inconn io.Reader, outconn io.Writer, size float64, step float64, opts CmhOptions) error {
+	h := handle("SlidingSyncCmhFull: %w")
+	if e := CheckWindow(size, step); e != nil {
+		return h(e)
+	}
+	b := NewSyncReaderScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingCmh(b, size, step, opts))); e != nil {
+		return h(e)
+	}
+	if e := b.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
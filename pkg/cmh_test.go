@@ -0,0 +1,63 @@
+package slide
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestChiSquareSurvival(t *testing.T) {
+	if p := ChiSquareSurvival(2, 2); math.Abs(p - math.Exp(-1)) > 1e-12 {
+		t.Errorf("P(chi2_2 > 2) %v != %v", p, math.Exp(-1))
+	}
+	if p := ChiSquareSurvival(3.841459, 1); math.Abs(p - 0.05) > 1e-6 {
+		t.Errorf("P(chi2_1 > 3.84) %v != 0.05", p)
+	}
+	if p := FisherCombined([]float64{1, 1, math.NaN()}); p != 1 {
+		t.Errorf("Fisher combined p of ones %v != 1", p)
+	}
+	for _, c := range []struct{ x, expect float64 }{{2000, 0.4957947558}, {2200, 0.0010593233}, {1900, 0.9449453138}} {
+		if p := ChiSquareSurvival(c.x, 2000); math.Abs(p - c.expect) > 1e-8 {
+			t.Errorf("P(chi2_2000 > %v) %v != %v", c.x, p, c.expect)
+		}
+	}
+}
+
+func TestFisherCombinedLarge(t *testing.T) {
+	ps := make([]float64, 5000)
+	for i := range ps {
+		ps[i] = (float64(i) + 0.5) / float64(len(ps))
+	}
+	if p := FisherCombined(ps); math.IsNaN(p) || p < 0.1 || p > 1 {
+		t.Errorf("Fisher combined p of 5000 uniform p-values %v", p)
+	}
+	for i := range ps {
+		ps[i] = 1e-3
+	}
+	if p := FisherCombined(ps); math.IsNaN(p) || p > 1e-100 {
+		t.Errorf("Fisher combined p of 5000 p-values of 0.001 %v", p)
+	}
+}
+
+func TestCmh(t *testing.T) {
+	sync := "2L\t5\tA\t50:50:0:0:0:0\t20:80:0:0:0:0\t40:40:0:0:0:0\t40:40:0:0:0:0\n"
+	opts := CmhOptions{Pairs: [][2]int{{0, 1}}, Threshold: 0.05}
+	var sites []BedEntry
+	for b := range SiteCmh(NewSyncReaderScanner(strings.NewReader(sync)), opts) {
+		sites = append(sites, b)
+	}
+	if len(sites) != 1 {
+		t.Fatalf("got %v sites, want 1", len(sites))
+	}
+	// E = 35, V = 100 * 100 * 70 * 130 / (200^2 * 199), |50 - 35| - 0.5 = 14.5.
+	stat := 14.5 * 14.5 / (100.0 * 100 * 70 * 130 / (200 * 200 * 199))
+	f := sites[0].Other.(CmhFields)
+	if math.Abs(f.Stat - stat) > 1e-9 || f.Replicates != 1 {
+		t.Errorf("CMH %+v, want stat %v", f, stat)
+	}
+
+	w := WindowCmh(sites, opts.Threshold)
+	if w.Sites != 1 || w.BelowThreshold != 1 || w.MinP != sites[0].Val {
+		t.Errorf("window %+v", w)
+	}
+}
@@ -0,0 +1,209 @@
+package slide
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+type DiversityOptions struct {
+	// Pop is the 0-based population to analyse.
+	Pop int
+	// PoolSize is the haploid pool size for pool-seq data. Zero means the
+	// counts are called allele copies, as from VcfScanner.
+	PoolSize float64
+	// MinCoverage and MaxCoverage bound the major plus minor allele count
+	// of a callable site. A MaxCoverage of zero means no upper bound.
+	MinCoverage float64
+	MaxCoverage float64
+	// MinCount is the minimum count of each allele for a site to be
+	// segregating. Values below one are treated as one.
+	MinCount float64
+	// WindowCallable divides by the window length instead of the number of
+	// callable sites, for input that lists only variant sites.
+	WindowCallable bool
+}
+
+func (o DiversityOptions) Validate() error {
+	h := handle("DiversityOptions.Validate: %w")
+	if o.Pop < 0 {
+		return h(fmt.Errorf("population %v < 0", o.Pop))
+	}
+	if o.PoolSize != 0 && o.PoolSize < 2 {
+		return h(fmt.Errorf("pool size %v must be 0 or at least 2", o.PoolSize))
+	}
+	return nil
+}
+
+type DiversityWindow struct {
+	Callable float64
+	Segregating int
+	ThetaPi float64
+	ThetaW float64
+	TajimaD float64
+}
+
+func (w DiversityWindow) Columns() []string {
+	return []string{"callable", "segregating", "theta_pi", "theta_w", "tajima_d"}
+}
+
+func (w DiversityWindow) Values() []interface{} {
+	return []interface{}{w.Callable, w.Segregating, w.ThetaPi, w.ThetaW, w.TajimaD}
+}
+
+// DiversityEstimator computes θπ, θW and Tajima's D from PopAlleleCounts
+// sites. For pool-seq data θπ is corrected by M/(M-1) * n/(n-1) for
+// coverage M and pool size n, and θW uses the correction of Kofler et al.
+// (2011) for pool size, coverage and MinCount. Tajima's D uses the
+// classical variance with the pool size as sample size, so for pools it
+// is an approximation.
+type DiversityEstimator struct {
+	Opts DiversityOptions
+	poolA map[float64]float64
+}
+
+func NewDiversityEstimator(opts DiversityOptions) *DiversityEstimator {
+	if opts.MinCount < 1 {
+		opts.MinCount = 1
+	}
+	return &DiversityEstimator{Opts: opts, poolA: map[float64]float64{}}
+}
+
+func harmonic(n float64) float64 {
+	a := 0.0
+	for i := 1.0; i < n; i++ {
+		a += 1 / i
+	}
+	return a
+}
+
+func binomialPmf(k, n, p float64) float64 {
+	lnk, _ := math.Lgamma(n + 1)
+	lk, _ := math.Lgamma(k + 1)
+	lnmk, _ := math.Lgamma(n - k + 1)
+	return math.Exp(lnk - lk - lnmk + k * math.Log(p) + (n - k) * math.Log1p(-p))
+}
+
+// poolWattersonA is the expected number of segregating sites per unit θ
+// seen in a pool of size n sequenced to coverage m, counting only sites
+// where each allele has at least b reads.
+func (d *DiversityEstimator) poolWattersonA(m float64) float64 {
+	if a, ok := d.poolA[m]; ok {
+		return a
+	}
+	n, b := d.Opts.PoolSize, d.Opts.MinCount
+	a := 0.0
+	for k := 1.0; k < n; k++ {
+		q := k / n
+		for r := b; r <= m - b; r++ {
+			a += binomialPmf(r, m, q) / k
+		}
+	}
+	d.poolA[m] = a
+	return a
+}
+
+// Window computes diversity over items, a window of length winLen.
+func (d *DiversityEstimator) Window(items []BedEntry, winLen float64) DiversityWindow {
+	var out DiversityWindow
+	var sumPi, sumW, sumN float64
+	minCov := math.Max(d.Opts.MinCoverage, 2)
+
+	for _, b := range items {
+		f, ok := b.Other.(PopAlleleCounts)
+		if !ok || d.Opts.Pop >= f.NPops() {
+			continue
+		}
+		major, minor := f.MajorMinorCounts(d.Opts.Pop)
+		n := major + minor
+		if n < minCov || (d.Opts.MaxCoverage > 0 && n > d.Opts.MaxCoverage) {
+			continue
+		}
+		out.Callable++
+		if math.Min(major, minor) < d.Opts.MinCount {
+			continue
+		}
+		out.Segregating++
+
+		if d.Opts.PoolSize > 0 {
+			p := minor / n
+			sumPi += 2 * p * (1 - p) * n / (n - 1) * d.Opts.PoolSize / (d.Opts.PoolSize - 1)
+			if a := d.poolWattersonA(n); a > 0 {
+				sumW += 1 / a
+			}
+			sumN += d.Opts.PoolSize
+		} else {
+			sumPi += 2 * major * minor / (n * (n - 1))
+			sumW += 1 / harmonic(n)
+			sumN += n
+		}
+	}
+
+	l := out.Callable
+	if d.Opts.WindowCallable {
+		l = winLen
+	}
+	out.ThetaPi = ratio(sumPi, l)
+	out.ThetaW = ratio(sumW, l)
+	out.TajimaD = math.NaN()
+	if out.Segregating > 0 {
+		out.TajimaD = tajimaD(sumPi, sumW, float64(out.Segregating), math.Round(sumN / float64(out.Segregating)))
+	}
+	return out
+}
+
+// tajimaD computes Tajima's D from summed per-site diversity pi, summed
+// Watterson contributions w, segregating site count s and sample size n.
+func tajimaD(pi, w, s, n float64) float64 {
+	if n < 4 {
+		return math.NaN()
+	}
+	a1 := harmonic(n)
+	a2 := 0.0
+	for i := 1.0; i < n; i++ {
+		a2 += 1 / (i * i)
+	}
+	b1 := (n + 1) / (3 * (n - 1))
+	b2 := 2 * (n * n + n + 3) / (9 * n * (n - 1))
+	c1 := b1 - 1 / a1
+	c2 := b2 - (n + 2) / (a1 * n) + a2 / (a1 * a1)
+	e1 := c1 / a1
+	e2 := c2 / (a1 * a1 + a2)
+	return ratio(pi - w, math.Sqrt(e1 * s + e2 * s * (s - 1)))
+}
+
+// SlidingDiversity reports diversity for each window on the Slider grid,
+// with θπ in Val.
+func SlidingDiversity(in BedOutputScanner, size float64, step float64, opts DiversityOptions) <-chan BedEntry {
+	s := NewSlider(in, size, step)
+	d := NewDiversityEstimator(opts)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for s.Step() {
+			w := d.Window(s.Entries(), s.Right - s.Left)
+			out <- BedEntry{Chrom: s.Chrom, Left: s.Left, Right: s.Right, Val: w.ThetaPi, Other: w}
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SlidingSyncDiversityFull(inconn io.Reader, outconn io.Writer, size float64, step float64, opts DiversityOptions) error {
+	h := handle("SlidingSyncDiversityFull: %w")
+	if e := CheckWindow(size, step); e != nil {
+		return h(e)
+	}
+	if e := opts.Validate(); e != nil {
+		return h(e)
+	}
+	b := NewSyncReaderScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingDiversity(b, size, step, opts))); e != nil {
+		return h(e)
+	}
+	if e := b.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
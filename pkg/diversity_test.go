@@ -0,0 +1,52 @@
+package slide
+
+import (
+	"math"
+	"testing"
+)
+
+func vcfSite(pos float64, ref, alt int64) BedEntry {
+	return BedEntry{Chrom: "chr1", Left: pos, Right: pos + 1, Val: 1, Other: VcfFields{Ref: "A", Alt: []string{"T"}, Counts: [][]int64{{ref, alt}}}}
+}
+
+func TestDiversity(t *testing.T) {
+	sites := []BedEntry{vcfSite(0, 3, 1), vcfSite(1, 2, 2), vcfSite(2, 4, 0)}
+	w := NewDiversityEstimator(DiversityOptions{}).Window(sites, 10)
+
+	pi := (0.5 + 2.0 / 3) / 3
+	thetaW := 2 / (1 + 1.0 / 2 + 1.0 / 3) / 3
+	if w.Callable != 3 || w.Segregating != 2 || math.Abs(w.ThetaPi - pi) > 1e-9 || math.Abs(w.ThetaW - thetaW) > 1e-9 {
+		t.Errorf("window %+v, want theta_pi %v theta_w %v", w, pi, thetaW)
+	}
+	if !(w.TajimaD > 0) {
+		t.Errorf("Tajima's D %v should be positive", w.TajimaD)
+	}
+
+	w = NewDiversityEstimator(DiversityOptions{WindowCallable: true}).Window(sites, 10)
+	if math.Abs(w.ThetaPi - pi * 3 / 10) > 1e-9 {
+		t.Errorf("theta_pi per window bp %v != %v", w.ThetaPi, pi * 3 / 10)
+	}
+}
+
+func TestPoolWatterson(t *testing.T) {
+	// With a very large pool and b = 1, the correction approaches the
+	// classical a_n for a sample of size equal to the coverage.
+	d := NewDiversityEstimator(DiversityOptions{PoolSize: 2000})
+	a := d.poolWattersonA(5)
+	if math.Abs(a - harmonic(5)) > 0.01 {
+		t.Errorf("pool a %v != %v", a, harmonic(5))
+	}
+}
+
+func TestDiversityOptionsValidate(t *testing.T) {
+	for _, size := range []float64{1, 0.5, -2} {
+		if err := (DiversityOptions{PoolSize: size}).Validate(); err == nil {
+			t.Errorf("pool size %v accepted", size)
+		}
+	}
+	for _, size := range []float64{0, 2, 100} {
+		if err := (DiversityOptions{PoolSize: size}).Validate(); err != nil {
+			t.Errorf("pool size %v: %v", size, err)
+		}
+	}
+}
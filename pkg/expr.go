@@ -0,0 +1,430 @@
+package slide
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed expression over a BedEntry, such as "abs(v)",
+// "-log10(v)" or "v > 2 && len >= 100".
+//
+// Variables are v (the value), start, end, len and other (a float64 Other,
+// or NaN). Numbers, the constants nan, inf and pi, the operators
+// + - * / ^, comparisons, && || ! and parentheses are supported, as are the
+// functions abs, sqrt, exp, log, log2, log10, min, max, clamp(x, lo, hi) and
+// isnan. Comparisons and logical operators give 1 for true and 0 for false.
+type Expr struct {
+	Source string
+	root exprNode
+}
+
+type exprNode struct {
+	eval func(b BedEntry) float64
+	pred bool
+}
+
+// Eval evaluates the expression for b.
+func (e *Expr) Eval(b BedEntry) float64 {
+	return e.root.eval(b)
+}
+
+// IsPredicate reports whether the expression is a comparison or logical
+// operation, and so is better used to filter entries than to transform
+// them.
+func (e *Expr) IsPredicate() bool {
+	return e.root.pred
+}
+
+func (e *Expr) String() string {
+	return e.Source
+}
+
+// Apply transforms the entries of in with the expression: a predicate
+// keeps the entries for which it is true, and any other expression replaces
+// Val.
+func (e *Expr) Apply(in BedOutputScanner) *BedEntryScanner {
+	if e.IsPredicate() {
+		return FilterEntries(in, func(b BedEntry) bool {
+			return e.Eval(b) != 0
+		})
+	}
+	return MapEntries(in, func(b BedEntry) BedEntry {
+		b.Val = e.Eval(b)
+		return b
+	})
+}
+
+// ExprColumn is a column computed by an Expr. Inner is the entry's original
+// Other, whose columns are written first.
+type ExprColumn struct {
+	Name string
+	Value float64
+	Inner interface{}
+}
+
+func (c ExprColumn) Columns() []string {
+	names, _ := ExtraColumns(BedEntry{Other: c.Inner})
+	return append(names, c.Name)
+}
+
+func (c ExprColumn) Values() []interface{} {
+	_, vals := ExtraColumns(BedEntry{Other: c.Inner})
+	return append(vals, c.Value)
+}
+
+// Column adds the value of the expression for each entry of in as a column
+// called name, leaving Val unchanged.
+func (e *Expr) Column(in BedOutputScanner, name string) *BedEntryScanner {
+	return MapEntries(in, func(b BedEntry) BedEntry {
+		b.Other = ExprColumn{Name: name, Value: e.Eval(b), Inner: b.Other}
+		return b
+	})
+}
+
+func ParseExpr(s string) (*Expr, error) {
+	p := exprParser{src: s}
+	if e := p.tokenize(); e != nil {
+		return nil, fmt.Errorf("ParseExpr: %q: %w", s, e)
+	}
+	root, e := p.parseOr()
+	if e == nil && p.pos < len(p.toks) {
+		e = fmt.Errorf("unexpected %q", p.toks[p.pos])
+	}
+	if e != nil {
+		return nil, fmt.Errorf("ParseExpr: %q: %w", s, e)
+	}
+	return &Expr{Source: s, root: root}, nil
+}
+
+// Exprs is a flag.Value collecting expressions from repeated flags, applied
+// in order.
+type Exprs []*Expr
+
+func (x *Exprs) String() string {
+	var out []string
+	for _, e := range *x {
+		out = append(out, e.Source)
+	}
+	return strings.Join(out, "; ")
+}
+
+func (x *Exprs) Set(s string) error {
+	e, err := ParseExpr(s)
+	if err != nil {
+		return err
+	}
+	*x = append(*x, e)
+	return nil
+}
+
+func (x Exprs) Apply(in BedOutputScanner) BedOutputScanner {
+	for _, e := range x {
+		in = e.Apply(in)
+	}
+	return in
+}
+
+type exprParser struct {
+	src string
+	toks []string
+	pos int
+}
+
+var exprOperators = []string{"&&", "||", ">=", "<=", "==", "!=", ">", "<", "+", "-", "*", "/", "^", "!", "(", ")", ","}
+
+func (p *exprParser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || s[j] == '.') {
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				k := j + 1
+				if k < len(s) && (s[k] == '+' || s[k] == '-') {
+					k++
+				}
+				if k < len(s) && unicode.IsDigit(rune(s[k])) {
+					for j = k; j < len(s) && unicode.IsDigit(rune(s[j])); j++ {}
+				}
+			}
+			p.toks = append(p.toks, s[i:j])
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_') {
+				j++
+			}
+			p.toks = append(p.toks, s[i:j])
+			i = j
+		default:
+			found := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(s[i:], op) {
+					p.toks = append(p.toks, op)
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *exprParser) expect(t string) error {
+	if got := p.next(); got != t {
+		if got == "" {
+			return fmt.Errorf("expected %q at end of expression", t)
+		}
+		return fmt.Errorf("expected %q, got %q", t, got)
+	}
+	return nil
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	l, e := p.parseAnd()
+	for e == nil && p.peek() == "||" {
+		p.next()
+		var r exprNode
+		if r, e = p.parseAnd(); e != nil {
+			break
+		}
+		lf, rf := l.eval, r.eval
+		l = exprNode{pred: true, eval: func(b BedEntry) float64 { return truth(lf(b) != 0 || rf(b) != 0) }}
+	}
+	return l, e
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	l, e := p.parseCmp()
+	for e == nil && p.peek() == "&&" {
+		p.next()
+		var r exprNode
+		if r, e = p.parseCmp(); e != nil {
+			break
+		}
+		lf, rf := l.eval, r.eval
+		l = exprNode{pred: true, eval: func(b BedEntry) float64 { return truth(lf(b) != 0 && rf(b) != 0) }}
+	}
+	return l, e
+}
+
+var exprComparisons = map[string]func(x, y float64) bool{
+	">": func(x, y float64) bool { return x > y },
+	"<": func(x, y float64) bool { return x < y },
+	">=": func(x, y float64) bool { return x >= y },
+	"<=": func(x, y float64) bool { return x <= y },
+	"==": func(x, y float64) bool { return x == y },
+	"!=": func(x, y float64) bool { return x != y },
+}
+
+func (p *exprParser) parseCmp() (exprNode, error) {
+	l, e := p.parseAdd()
+	if e != nil {
+		return l, e
+	}
+	cmp, ok := exprComparisons[p.peek()]
+	if !ok {
+		return l, nil
+	}
+	p.next()
+	r, e := p.parseAdd()
+	if e != nil {
+		return r, e
+	}
+	lf, rf := l.eval, r.eval
+	return exprNode{pred: true, eval: func(b BedEntry) float64 { return truth(cmp(lf(b), rf(b))) }}, nil
+}
+
+func (p *exprParser) parseAdd() (exprNode, error) {
+	l, e := p.parseMul()
+	for e == nil && (p.peek() == "+" || p.peek() == "-") {
+		op := p.next()
+		var r exprNode
+		if r, e = p.parseMul(); e != nil {
+			break
+		}
+		lf, rf := l.eval, r.eval
+		if op == "+" {
+			l = exprNode{eval: func(b BedEntry) float64 { return lf(b) + rf(b) }}
+		} else {
+			l = exprNode{eval: func(b BedEntry) float64 { return lf(b) - rf(b) }}
+		}
+	}
+	return l, e
+}
+
+func (p *exprParser) parseMul() (exprNode, error) {
+	l, e := p.parseUnary()
+	for e == nil && (p.peek() == "*" || p.peek() == "/") {
+		op := p.next()
+		var r exprNode
+		if r, e = p.parseUnary(); e != nil {
+			break
+		}
+		lf, rf := l.eval, r.eval
+		if op == "*" {
+			l = exprNode{eval: func(b BedEntry) float64 { return lf(b) * rf(b) }}
+		} else {
+			l = exprNode{eval: func(b BedEntry) float64 { return lf(b) / rf(b) }}
+		}
+	}
+	return l, e
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	switch p.peek() {
+	case "-":
+		p.next()
+		x, e := p.parseUnary()
+		f := x.eval
+		return exprNode{eval: func(b BedEntry) float64 { return -f(b) }}, e
+	case "!":
+		p.next()
+		x, e := p.parseUnary()
+		f := x.eval
+		return exprNode{pred: true, eval: func(b BedEntry) float64 { return truth(f(b) == 0) }}, e
+	}
+	return p.parsePow()
+}
+
+func (p *exprParser) parsePow() (exprNode, error) {
+	l, e := p.parsePrimary()
+	if e != nil || p.peek() != "^" {
+		return l, e
+	}
+	p.next()
+	r, e := p.parseUnary()
+	if e != nil {
+		return r, e
+	}
+	lf, rf := l.eval, r.eval
+	return exprNode{eval: func(b BedEntry) float64 { return math.Pow(lf(b), rf(b)) }}, nil
+}
+
+var exprVariables = map[string]func(b BedEntry) float64{
+	"v": func(b BedEntry) float64 { return b.Val },
+	"start": func(b BedEntry) float64 { return b.Left },
+	"end": func(b BedEntry) float64 { return b.Right },
+	"len": func(b BedEntry) float64 { return b.Right - b.Left },
+	"other": func(b BedEntry) float64 {
+		if o, ok := b.Other.(float64); ok {
+			return o
+		}
+		return math.NaN()
+	},
+}
+
+var exprConstants = map[string]float64{
+	"nan": math.NaN(),
+	"inf": math.Inf(1),
+	"pi": math.Pi,
+}
+
+var exprFunctions = map[string]struct {
+	nargs int
+	f func(x []float64) float64
+}{
+	"abs": {1, func(x []float64) float64 { return math.Abs(x[0]) }},
+	"sqrt": {1, func(x []float64) float64 { return math.Sqrt(x[0]) }},
+	"exp": {1, func(x []float64) float64 { return math.Exp(x[0]) }},
+	"log": {1, func(x []float64) float64 { return math.Log(x[0]) }},
+	"log2": {1, func(x []float64) float64 { return math.Log2(x[0]) }},
+	"log10": {1, func(x []float64) float64 { return math.Log10(x[0]) }},
+	"isnan": {1, func(x []float64) float64 { return truth(math.IsNaN(x[0])) }},
+	"min": {2, func(x []float64) float64 { return math.Min(x[0], x[1]) }},
+	"max": {2, func(x []float64) float64 { return math.Max(x[0], x[1]) }},
+	"clamp": {3, func(x []float64) float64 { return math.Min(math.Max(x[0], x[1]), x[2]) }},
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return exprNode{}, fmt.Errorf("unexpected end of expression")
+	case t == "(":
+		x, e := p.parseOr()
+		if e != nil {
+			return x, e
+		}
+		return x, p.expect(")")
+	case unicode.IsDigit(rune(t[0])) || t[0] == '.':
+		v, e := strconv.ParseFloat(t, 64)
+		if e != nil {
+			return exprNode{}, fmt.Errorf("invalid number %q", t)
+		}
+		return exprNode{eval: func(BedEntry) float64 { return v }}, nil
+	}
+
+	name := strings.ToLower(t)
+	if fn, ok := exprFunctions[name]; ok {
+		if e := p.expect("("); e != nil {
+			return exprNode{}, e
+		}
+		var args []func(BedEntry) float64
+		pred := name == "isnan"
+		for {
+			x, e := p.parseOr()
+			if e != nil {
+				return x, e
+			}
+			args = append(args, x.eval)
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		if e := p.expect(")"); e != nil {
+			return exprNode{}, e
+		}
+		if len(args) != fn.nargs {
+			return exprNode{}, fmt.Errorf("%s takes %d arguments, got %d", name, fn.nargs, len(args))
+		}
+		return exprNode{pred: pred, eval: func(b BedEntry) float64 {
+			x := make([]float64, len(args))
+			for i, a := range args {
+				x[i] = a(b)
+			}
+			return fn.f(x)
+		}}, nil
+	}
+	if f, ok := exprVariables[name]; ok {
+		return exprNode{eval: f}, nil
+	}
+	if c, ok := exprConstants[name]; ok {
+		return exprNode{eval: func(BedEntry) float64 { return c }}, nil
+	}
+	return exprNode{}, fmt.Errorf("unknown name %q", t)
+}
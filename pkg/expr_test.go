@@ -0,0 +1,90 @@
+package slide
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExprEval(t *testing.T) {
+	b := BedEntry{Chrom: "chr1", Left: 100, Right: 250, Val: 0.001, Other: 4.0}
+	for src, want := range map[string]float64{
+		"-log10(v)": 3,
+		"abs(-2) * 3 + 1": 7,
+		"2 ^ 3 ^ 2": 512,
+		"len / 10": 15,
+		"v > 0.01 || len >= 150": 1,
+		"!(start == 100)": 0,
+		"clamp(other, 0, 2.5)": 2.5,
+		"max(1e-3, 5E-4) * 1000": 1,
+		"isnan(nan)": 1,
+	} {
+		e, err := ParseExpr(src)
+		if err != nil {
+			t.Errorf("ParseExpr(%q): %v", src, err)
+			continue
+		}
+		if got := e.Eval(b); math.Abs(got - want) > 1e-12 {
+			t.Errorf("%q = %v, want %v", src, got, want)
+		}
+	}
+
+	for _, bad := range []string{"", "v +", "foo(v)", "min(v)", "(v", "v $ 2", "v 2"} {
+		if _, err := ParseExpr(bad); err == nil {
+			t.Errorf("ParseExpr(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestExprApply(t *testing.T) {
+	var pipeline Exprs
+	for _, s := range []string{"abs(v)", "v > 1", "v * 2"} {
+		if err := pipeline.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pipeline[0].IsPredicate() || !pipeline[1].IsPredicate() {
+		t.Errorf("predicate detection wrong")
+	}
+	out := collectVals(pipeline.Apply(NewBedSliceScanner(pvals(-3, 0.5, 2))))
+	if len(out) != 2 || out[0] != 6 || out[1] != 4 {
+		t.Errorf("pipeline output %v", out)
+	}
+}
+
+func TestExprColumn(t *testing.T) {
+	e, err := ParseExpr("log10(v)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := e.Column(NewBedSliceScanner(pvals(100)), "log10")
+	if !s.Scan() {
+		t.Fatal("no entry")
+	}
+	b := s.Entry()
+	names, vals := ExtraColumns(b)
+	if b.Val != 100 || len(names) != 1 || names[0] != "log10" || vals[0] != 2.0 {
+		t.Errorf("entry %v with columns %v %v", b, names, vals)
+	}
+}
+
+func collectVals(in BedOutputScanner) []float64 {
+	var out []float64
+	for in.Scan() {
+		out = append(out, in.Entry().Val)
+	}
+	return out
+}
+
+func TestRank(t *testing.T) {
+	out := collectVals(Rank(NewBedSliceScanner(pvals(5, 1, math.NaN(), 5, 3))))
+	want := []float64{3.5, 1, math.NaN(), 3.5, 2}
+	for i := range want {
+		if !(out[i] == want[i] || math.IsNaN(out[i]) && math.IsNaN(want[i])) {
+			t.Errorf("ranks %v, want %v", out, want)
+			break
+		}
+	}
+	if got := collectVals(Clamp(Threshold(NewBedSliceScanner(pvals(-1, 2, 9)), 0), 0, 5)); len(got) != 2 || got[1] != 5 {
+		t.Errorf("threshold and clamp %v", got)
+	}
+}
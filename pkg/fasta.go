@@ -0,0 +1,382 @@
+package slide
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FaEntry is a sequence record. Start is the 0-based coordinate of Seq[0],
+// which is 0 unless the record is a region fetched from an indexed file.
+type FaEntry struct {
+	Name string
+	Start int64
+	Seq []byte
+}
+
+type FaOutputScanner interface {
+	Scan() bool
+	Entry() FaEntry
+}
+
+type FaScanner struct {
+	r *bufio.Reader
+	cur FaEntry
+	nextName string
+	haveNext bool
+	done bool
+	err error
+}
+
+func NewFaScanner(r io.Reader) *FaScanner {
+	return &FaScanner{r: bufio.NewReader(r)}
+}
+
+func faName(header []byte) string {
+	fields := strings.Fields(string(header[1:]))
+	if len(fields) < 1 {
+		return ""
+	}
+	return fields[0]
+}
+
+func (s *FaScanner) Scan() bool {
+	h := handle("FaScanner.Scan: %w")
+	if s.done {
+		return false
+	}
+
+	name, inRecord := s.nextName, s.haveNext
+	var seq []byte
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			s.err = h(err)
+			s.done = true
+			return false
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) > 0 && line[0] == '>' {
+			if inRecord {
+				s.nextName, s.haveNext = faName(line), true
+				s.cur = FaEntry{Name: name, Seq: seq}
+				return true
+			}
+			name, inRecord = faName(line), true
+		} else if len(line) > 0 {
+			if !inRecord {
+				s.err = h(fmt.Errorf("sequence before first header"))
+				s.done = true
+				return false
+			}
+			seq = append(seq, line...)
+		}
+
+		if err == io.EOF {
+			s.done = true
+			if inRecord {
+				s.cur = FaEntry{Name: name, Seq: seq}
+			}
+			return inRecord
+		}
+	}
+}
+
+func (s *FaScanner) Entry() FaEntry {
+	return s.cur
+}
+
+func (s *FaScanner) Error() error {
+	return s.err
+}
+
+type FaiEntry struct {
+	Name string
+	Length int64
+	Offset int64
+	LineBases int64
+	LineWidth int64
+}
+
+func ReadFai(r io.Reader) ([]FaiEntry, error) {
+	h := handle("ReadFai: %w")
+	var out []FaiEntry
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.Split(s.Text(), "\t")
+		if len(line) < 5 {
+			return nil, h(fmt.Errorf("len(line) %v < 5", len(line)))
+		}
+		f := FaiEntry{Name: line[0]}
+		var e error
+		for i, p := range []*int64{&f.Length, &f.Offset, &f.LineBases, &f.LineWidth} {
+			*p, e = strconv.ParseInt(line[i+1], 10, 64)
+			if e != nil { return nil, h(e) }
+		}
+		if f.Length > 0 && (f.LineBases < 1 || f.LineWidth < f.LineBases) {
+			return nil, h(fmt.Errorf("sequence %v: invalid line bases %v and line width %v", f.Name, f.LineBases, f.LineWidth))
+		}
+		out = append(out, f)
+	}
+	if e := s.Err(); e != nil {
+		return nil, h(e)
+	}
+	return out, nil
+}
+
+// IndexedFa gives random access to a FASTA file through its .fai index.
+type IndexedFa struct {
+	R io.ReaderAt
+	Index []FaiEntry
+	byName map[string]int
+	closer io.Closer
+}
+
+func NewIndexedFa(r io.ReaderAt, index []FaiEntry) *IndexedFa {
+	f := &IndexedFa{R: r, Index: index, byName: map[string]int{}}
+	for i, e := range index {
+		f.byName[e.Name] = i
+	}
+	return f
+}
+
+// OpenIndexedFa opens path and its index, path + ".fai".
+func OpenIndexedFa(path string) (*IndexedFa, error) {
+	h := handle("OpenIndexedFa: %w")
+	fai, e := os.Open(path + ".fai")
+	if e != nil { return nil, h(e) }
+	defer fai.Close()
+
+	index, e := ReadFai(fai)
+	if e != nil { return nil, h(e) }
+
+	fa, e := os.Open(path)
+	if e != nil { return nil, h(e) }
+
+	f := NewIndexedFa(fa, index)
+	f.closer = fa
+	return f, nil
+}
+
+func (f *IndexedFa) Close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+func (f *IndexedFa) Entry(chrom string) (FaiEntry, bool) {
+	i, ok := f.byName[chrom]
+	if !ok {
+		return FaiEntry{}, false
+	}
+	return f.Index[i], true
+}
+
+// Fetch returns the bases in [start, end) of chrom, clipped to its length.
+func (f *IndexedFa) Fetch(chrom string, start, end int64) ([]byte, error) {
+	h := handle("IndexedFa.Fetch: %w")
+	fe, ok := f.Entry(chrom)
+	if !ok {
+		return nil, h(fmt.Errorf("no sequence %q in index", chrom))
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > fe.Length {
+		end = fe.Length
+	}
+	if start >= end {
+		return nil, nil
+	}
+
+	offset := func(i int64) int64 {
+		return fe.Offset + (i / fe.LineBases) * fe.LineWidth + i % fe.LineBases
+	}
+	first := offset(start)
+	last := offset(end - 1) + 1
+	buf := make([]byte, last - first)
+	n, e := f.R.ReadAt(buf, first)
+	if e != nil && !(e == io.EOF && int64(n) == last - first) {
+		return nil, h(e)
+	}
+
+	out := buf[:0]
+	for _, c := range buf {
+		if c != '\n' && c != '\r' {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+type IndexedFaScanner struct {
+	Fa *IndexedFa
+	Regions []BedEntry
+	pos int
+	cur FaEntry
+	err error
+}
+
+// Scanner iterates over regions of the indexed file, or over every
+// sequence in index order when no regions are given.
+func (f *IndexedFa) Scanner(regions ...BedEntry) *IndexedFaScanner {
+	if len(regions) == 0 {
+		for _, e := range f.Index {
+			regions = append(regions, BedEntry{Chrom: e.Name, Left: 0, Right: float64(e.Length)})
+		}
+	}
+	return &IndexedFaScanner{Fa: f, Regions: regions}
+}
+
+func (s *IndexedFaScanner) Scan() bool {
+	if s.err != nil || s.pos >= len(s.Regions) {
+		return false
+	}
+	r := s.Regions[s.pos]
+	s.pos++
+
+	start := int64(r.Left)
+	if start < 0 {
+		start = 0
+	}
+	seq, e := s.Fa.Fetch(r.Chrom, start, int64(r.Right))
+	if e != nil {
+		s.err = e
+		return false
+	}
+	s.cur = FaEntry{Name: r.Chrom, Start: start, Seq: seq}
+	return true
+}
+
+func (s *IndexedFaScanner) Entry() FaEntry {
+	return s.cur
+}
+
+func (s *IndexedFaScanner) Error() error {
+	return s.err
+}
+
+// SeqWindows calls f for each window of seq on the same size/step grid as
+// Slider: windows start at multiples of step from the chromosome start,
+// and the last window is the first one reaching the end of the sequence.
+// Like Slider's, windows keep their full bounds; only seq is clipped to the
+// sequence.
+func SeqWindows(fa FaEntry, size float64, step float64, f func(left, right float64, seq []byte)) {
+	mustWindow(size, step)
+	start := float64(fa.Start)
+	end := start + float64(len(fa.Seq))
+	if end <= start {
+		return
+	}
+	for left := math.Floor(start / step) * step; ; left += step {
+		right := left + size
+		wl := math.Max(left, start)
+		wr := math.Min(right, end)
+		if wl < wr {
+			f(left, right, fa.Seq[int64(wl) - fa.Start : int64(wr) - fa.Start])
+		}
+		if right >= end {
+			break
+		}
+	}
+}
+
+type SeqStats struct {
+	Bases int
+	GC float64
+	N float64
+	CpGOE float64
+	GCSkew float64
+	ATSkew float64
+}
+
+func (s SeqStats) Columns() []string {
+	return []string{"bases", "n_frac", "cpg_oe", "gc_skew", "at_skew"}
+}
+
+func (s SeqStats) Values() []interface{} {
+	return []interface{}{s.Bases, s.N, s.CpGOE, s.GCSkew, s.ATSkew}
+}
+
+func ratio(num, den float64) float64 {
+	if den == 0 {
+		return math.NaN()
+	}
+	return num / den
+}
+
+// SeqComposition computes base composition statistics for seq. Soft-masked
+// bases count like upper case ones, and any base other than ACGT counts as
+// N. CpG observed/expected is CpG * L / (C * G), L being the ACGT count.
+func SeqComposition(seq []byte) SeqStats {
+	var a, c, g, t, cpg float64
+	var prev byte
+	for _, b := range seq {
+		switch b {
+		case 'A', 'a':
+			a++
+		case 'C', 'c':
+			c++
+		case 'G', 'g':
+			g++
+			if prev == 'C' || prev == 'c' {
+				cpg++
+			}
+		case 'T', 't':
+			t++
+		}
+		prev = b
+	}
+
+	acgt := a + c + g + t
+	return SeqStats{
+		Bases: len(seq),
+		GC: ratio(g + c, acgt),
+		N: ratio(float64(len(seq)) - acgt, float64(len(seq))),
+		CpGOE: ratio(cpg * acgt, c * g),
+		GCSkew: ratio(g - c, g + c),
+		ATSkew: ratio(a - t, a + t),
+	}
+}
+
+// SlidingSeqStats reports SeqComposition for each window. Val holds the GC
+// fraction and Other the remaining SeqStats.
+func SlidingSeqStats(in FaOutputScanner, size float64, step float64) <-chan BedEntry {
+	mustWindow(size, step)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for in.Scan() {
+			fa := in.Entry()
+			SeqWindows(fa, size, step, func(left, right float64, seq []byte) {
+				stats := SeqComposition(seq)
+				out <- BedEntry{Chrom: fa.Name, Left: left, Right: right, Val: stats.GC, Other: stats}
+			})
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SlidingSeqStatsFull(inconn io.Reader, outconn io.Writer, size float64, step float64) error {
+	h := handle("SlidingSeqStatsFull: %w")
+	if e := CheckWindow(size, step); e != nil {
+		return h(e)
+	}
+	fa := NewFaScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingSeqStats(fa, size, step))); e != nil {
+		return h(e)
+	}
+	if e := fa.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package slide
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testFa = ">chr1 description\nACGT\nACGT\nNN\n>chr2\ngcgc\n"
+
+var testFai = "chr1\t10\t18\t4\t5\nchr2\t4\t37\t4\t5\n"
+
+func TestSlidingSeqStats(t *testing.T) {
+	var out []BedEntry
+	for b := range SlidingSeqStats(NewFaScanner(strings.NewReader(testFa)), 4, 2) {
+		out = append(out, b)
+	}
+
+	expect := []span3{{"chr1", 0, 4}, {"chr1", 2, 6}, {"chr1", 4, 8}, {"chr1", 6, 10}, {"chr2", 0, 4}}
+	if got := collectSpans(NewBedSliceScanner(out)); !reflect.DeepEqual(got, expect) {
+		t.Fatalf("out %v != expect %v", got, expect)
+	}
+
+	last := out[3].Other.(SeqStats)
+	if last.GC != 0.5 || last.N != 0.5 || last.GCSkew != 1 || !math.IsNaN(last.CpGOE) {
+		t.Errorf("chr1:6-10 stats %+v", last)
+	}
+	if gc := out[4].Other.(SeqStats); gc.GC != 1 || gc.CpGOE != 1 {
+		t.Errorf("chr2 stats %+v", gc)
+	}
+}
+
+func TestSeqWindowsUnclipped(t *testing.T) {
+	var got []span3
+	var bases []int
+	SeqWindows(FaEntry{Name: "chr2", Seq: []byte("gcgc")}, 3, 2, func(left, right float64, seq []byte) {
+		got = append(got, span3{"chr2", left, right})
+		bases = append(bases, len(seq))
+	})
+	expect := []span3{{"chr2", 0, 3}, {"chr2", 2, 5}}
+	if len(got) != len(expect) || got[0] != expect[0] || got[1] != expect[1] {
+		t.Errorf("windows %v != expect %v", got, expect)
+	}
+	if len(bases) != 2 || bases[1] != 2 {
+		t.Errorf("bases %v; want the last window clipped to 2 bases", bases)
+	}
+}
+
+func TestReadFaiBadLines(t *testing.T) {
+	for _, in := range []string{"chr1\t10\t6\t0\t1\n", "chr1\t10\t6\t4\t3\n"} {
+		if _, err := ReadFai(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadFai(%q) succeeded", in)
+		}
+	}
+	if _, err := ReadFai(strings.NewReader("empty\t0\t6\t0\t0\n")); err != nil {
+		t.Errorf("empty sequence: %v", err)
+	}
+}
+
+func TestIndexedFa(t *testing.T) {
+	index, err := ReadFai(strings.NewReader(testFai))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fa := NewIndexedFa(strings.NewReader(testFa), index)
+
+	seq, err := fa.Fetch("chr1", 2, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(seq) != "GTACGTN" {
+		t.Errorf("fetched %q != %q", seq, "GTACGTN")
+	}
+
+	s := fa.Scanner(BedEntry{Chrom: "chr2", Left: 1, Right: 100})
+	if !s.Scan() || string(s.Entry().Seq) != "cgc" || s.Entry().Start != 1 {
+		t.Errorf("region scan %+v, %v", s.Entry(), s.Error())
+	}
+}
@@ -0,0 +1,236 @@
+package slide
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+type FstMethod int
+
+const (
+	FstHudson FstMethod = iota
+	FstWeirCockerham
+	FstPool
+)
+
+var fstMethodNames = []string{"hudson", "wc", "pool"}
+
+func (m FstMethod) String() string {
+	if int(m) < len(fstMethodNames) {
+		return fstMethodNames[m]
+	}
+	return fmt.Sprintf("FstMethod(%d)", int(m))
+}
+
+func ParseFstMethod(s string) (FstMethod, error) {
+	for i, name := range fstMethodNames {
+		if strings.EqualFold(s, name) {
+			return FstMethod(i), nil
+		}
+	}
+	return 0, fmt.Errorf("ParseFstMethod: unknown method %q; choose one of %v", s, strings.Join(fstMethodNames, ", "))
+}
+
+type FstOptions struct {
+	Method FstMethod
+	// Pairs lists the 0-based population pairs to compare. All pairs are
+	// compared when it is nil.
+	Pairs [][2]int
+	// PoolSizes holds the haploid pool size of each population, as needed
+	// by FstPool.
+	PoolSizes []float64
+	// MinCoverage is the minimum number of major plus minor allele copies
+	// each population needs for a site to be used.
+	MinCoverage float64
+}
+
+// FstComponents returns the numerator and denominator of the Fst estimate
+// at one biallelic site, given the minor allele counts x and totals n of
+// two populations and, for FstPool, their haploid pool sizes. Window Fst
+// is the ratio of the summed numerators and denominators.
+//
+// FstHudson is the estimator of Bhatia et al. (2013), FstWeirCockerham the
+// haploid analysis of variance estimator of Weir and Cockerham (1984), and
+// FstPool the read-count estimator of Hivert et al. (2018).
+func FstComponents(method FstMethod, x1, n1, x2, n2, pool1, pool2 float64) (num float64, den float64, ok bool) {
+	if n1 < 2 || n2 < 2 {
+		return 0, 0, false
+	}
+	p1, p2 := x1 / n1, x2 / n2
+
+	switch method {
+	case FstHudson:
+		num = (p1 - p2) * (p1 - p2) - p1 * (1 - p1) / (n1 - 1) - p2 * (1 - p2) / (n2 - 1)
+		den = p1 * (1 - p2) + p2 * (1 - p1)
+		return num, den, true
+
+	case FstWeirCockerham:
+		ntot := n1 + n2
+		pbar := (x1 + x2) / ntot
+		msp := n1 * (p1 - pbar) * (p1 - pbar) + n2 * (p2 - pbar) * (p2 - pbar)
+		msg := (n1 * p1 * (1 - p1) + n2 * p2 * (1 - p2)) / (ntot - 2)
+		nc := ntot - (n1 * n1 + n2 * n2) / ntot
+		return msp - msg, msp + (nc - 1) * msg, true
+
+	case FstPool:
+		if pool1 < 2 || pool2 < 2 {
+			return 0, 0, false
+		}
+		c := [2]float64{n1, n2}
+		y1 := [2]float64{x1, x2}
+		pool := [2]float64{pool1, pool2}
+		c1 := n1 + n2
+		c2 := n1 * n1 + n2 * n2
+		var d2, d2star, ssi, ssp float64
+		ybar1 := (x1 + x2) / c1
+		for i := range c {
+			y2 := c[i] - y1[i]
+			d2 += c[i] / pool[i] + (pool[i] - 1) / pool[i]
+			d2star += c[i] * (c[i] / pool[i] + (pool[i] - 1) / pool[i])
+			ssi += y1[i] - y1[i] * y1[i] / c[i] + y2 - y2 * y2 / c[i]
+			q1 := y1[i] / c[i] - ybar1
+			q2 := y2 / c[i] - (1 - ybar1)
+			ssp += c[i] * (q1 * q1 + q2 * q2)
+		}
+		d2star /= c1
+		if d2 - d2star == 0 || c1 - d2 == 0 {
+			return 0, 0, false
+		}
+		nc := (c1 - c2 / c1) / (d2 - d2star)
+		msi := ssi / (c1 - d2)
+		msp := ssp / (d2 - d2star)
+		return msp - msi, msp + (nc - 1) * msi, true
+	}
+	return 0, 0, false
+}
+
+func allPairs(npops int) [][2]int {
+	var out [][2]int
+	for i := 0; i < npops; i++ {
+		for j := i + 1; j < npops; j++ {
+			out = append(out, [2]int{i, j})
+		}
+	}
+	return out
+}
+
+type FstWindow struct {
+	Pairs [][2]int
+	Fst []float64
+	Sites []int
+}
+
+func (w FstWindow) Columns() []string {
+	var out []string
+	for _, p := range w.Pairs {
+		out = append(out, fmt.Sprintf("fst_%d_%d", p[0] + 1, p[1] + 1))
+	}
+	for _, p := range w.Pairs {
+		out = append(out, fmt.Sprintf("sites_%d_%d", p[0] + 1, p[1] + 1))
+	}
+	return out
+}
+
+func (w FstWindow) Values() []interface{} {
+	var out []interface{}
+	for _, v := range w.Fst {
+		out = append(out, v)
+	}
+	for _, v := range w.Sites {
+		out = append(out, v)
+	}
+	return out
+}
+
+// WindowFst estimates Fst for each population pair from the sites in items
+// whose Other implements PopAlleleCounts, as a ratio of averages.
+func WindowFst(items []BedEntry, pairs [][2]int, opts FstOptions) FstWindow {
+	out := FstWindow{Pairs: pairs}
+	nums := make([]float64, len(pairs))
+	dens := make([]float64, len(pairs))
+	out.Sites = make([]int, len(pairs))
+
+	for _, b := range items {
+		f, ok := b.Other.(PopAlleleCounts)
+		if !ok {
+			continue
+		}
+		for i, p := range pairs {
+			if p[0] >= f.NPops() || p[1] >= f.NPops() {
+				continue
+			}
+			maj1, min1 := f.MajorMinorCounts(p[0])
+			maj2, min2 := f.MajorMinorCounts(p[1])
+			n1, n2 := maj1 + min1, maj2 + min2
+			if n1 < opts.MinCoverage || n2 < opts.MinCoverage {
+				continue
+			}
+			var pool1, pool2 float64
+			if p[0] < len(opts.PoolSizes) && p[1] < len(opts.PoolSizes) {
+				pool1, pool2 = opts.PoolSizes[p[0]], opts.PoolSizes[p[1]]
+			}
+			num, den, ok := FstComponents(opts.Method, min1, n1, min2, n2, pool1, pool2)
+			if !ok {
+				continue
+			}
+			nums[i] += num
+			dens[i] += den
+			out.Sites[i]++
+		}
+	}
+
+	out.Fst = make([]float64, len(pairs))
+	for i := range pairs {
+		out.Fst[i] = ratio(nums[i], dens[i])
+	}
+	return out
+}
+
+// SlidingFst reports WindowFst for each window, with the Fst of the first
+// pair in Val.
+func SlidingFst(in BedOutputScanner, size float64, step float64, opts FstOptions) <-chan BedEntry {
+	s := NewSlider(in, size, step)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		pairs := opts.Pairs
+		for s.Step() {
+			items := s.Entries()
+			if pairs == nil {
+				for _, b := range items {
+					if f, ok := b.Other.(PopAlleleCounts); ok {
+						pairs = allPairs(f.NPops())
+						break
+					}
+				}
+			}
+
+			w := WindowFst(items, pairs, opts)
+			entry := BedEntry{Chrom: s.Chrom, Left: s.Left, Right: s.Right, Val: math.NaN(), Other: w}
+			if len(w.Fst) > 0 {
+				entry.Val = w.Fst[0]
+			}
+			out <- entry
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SlidingSyncFstFull(inconn io.Reader, outconn io.Writer, size float64, step float64, opts FstOptions) error {
+	h := handle("SlidingSyncFstFull: %w")
+	if e := CheckWindow(size, step); e != nil {
+		return h(e)
+	}
+	b := NewSyncReaderScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingFst(b, size, step, opts))); e != nil {
+		return h(e)
+	}
+	if e := b.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
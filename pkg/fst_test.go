@@ -0,0 +1,55 @@
+package slide
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+var testVcf = `##fileformat=VCFv4.2
+#CHROM	POS	ID	REF	ALT	QUAL	FILTER	INFO	FORMAT	a1	a2	b1	b2
+chr1	10	.	A	G	.	PASS	.	GT	0/0	0/0	1/1	1/1
+chr1	20	.	C	T	.	PASS	.	GT:DP	0|1:5	0/1:3	0/1:2	1/0:9
+chr1	30	.	G	A,C	.	PASS	.	GT	./.	0/0	2/2	2/.
+`
+
+func TestFstComponents(t *testing.T) {
+	for _, m := range []FstMethod{FstHudson, FstWeirCockerham, FstPool} {
+		num, den, ok := FstComponents(m, 0, 10, 10, 10, 100, 100)
+		if !ok || math.Abs(num / den - 1) > 1e-9 {
+			t.Errorf("%v: fixed difference Fst %v/%v != 1", m, num, den)
+		}
+		num, den, ok = FstComponents(m, 5, 10, 5, 10, 100, 100)
+		if !ok || num / den > 0 {
+			t.Errorf("%v: identical populations Fst %v/%v > 0", m, num, den)
+		}
+	}
+}
+
+func TestVcfFst(t *testing.T) {
+	pops := map[string]int{"a1": 0, "a2": 0, "b1": 1, "b2": 1}
+	s := NewVcfScanner(strings.NewReader(testVcf), pops)
+	var sites []BedEntry
+	for s.Scan() {
+		sites = append(sites, s.Entry())
+	}
+	if err := s.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 3 {
+		t.Fatalf("read %v sites, want 3", len(sites))
+	}
+
+	f := sites[2].Other.(VcfFields)
+	if maj, min := f.MajorMinorCounts(1); maj != 3 || min != 0 {
+		t.Errorf("site 3 pop 2 counts %v %v", maj, min)
+	}
+
+	w := WindowFst(sites[:2], allPairs(2), FstOptions{Method: FstHudson})
+	// Site 1 contributes 1 - 0 - 0 over 1; site 2 contributes
+	// 0 - 2 * 0.25 / 3 over 0.5.
+	expect := (1 - 0.5 / 3) / 1.5
+	if w.Sites[0] != 2 || math.Abs(w.Fst[0] - expect) > 1e-9 {
+		t.Errorf("window Fst %v over %v sites != %v", w.Fst[0], w.Sites[0], expect)
+	}
+}
@@ -0,0 +1,293 @@
+package slide
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeneticEpsilon is the smallest width in cM given to an entry, so that
+// entries inside regions without recombination still overlap windows.
+const GeneticEpsilon = 1e-9
+
+var GeneticMapFormats = []string{"plink", "hapmap"}
+
+// GeneticMap converts between physical positions in bp and genetic
+// positions in cM by linear interpolation between map points. Outside the
+// points of a chromosome, positions are extrapolated at the chromosome's
+// mean rate, and genetic positions are never below 0.
+type GeneticMap struct {
+	chroms map[string]*chromMap
+}
+
+type chromMap struct {
+	bp []float64
+	cm []float64
+	// rate is the mean rate in cM per bp, used to extrapolate.
+	rate float64
+}
+
+type mapPoint struct {
+	bp float64
+	cm float64
+}
+
+// ReadGeneticMap reads a genetic map in one of GeneticMapFormats:
+//
+//   - plink: .map lines of chromosome, marker ID, position in cM and
+//     position in bp. Markers with negative positions are skipped.
+//   - hapmap: chromosome, position in bp, rate in cM/Mb and position in cM,
+//     or without the chromosome column, in which case all points are on
+//     chrom. Header lines are skipped.
+//
+// Within each chromosome, cM must not decrease with bp.
+func ReadGeneticMap(r io.Reader, format string, chrom string) (*GeneticMap, error) {
+	h := handle("ReadGeneticMap: %w")
+	points := map[string][]mapPoint{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || fields[0][0] == '#' {
+			continue
+		}
+		var c, bpField, cmField string
+		switch {
+		case format == "plink" && len(fields) == 4:
+			c, cmField, bpField = fields[0], fields[2], fields[3]
+		case format == "hapmap" && len(fields) == 4:
+			c, bpField, cmField = fields[0], fields[1], fields[3]
+		case format == "hapmap" && len(fields) == 3:
+			if chrom == "" {
+				return nil, h(fmt.Errorf("hapmap file without a chromosome column needs a chromosome"))
+			}
+			c, bpField, cmField = chrom, fields[0], fields[2]
+		case format == "plink" || format == "hapmap":
+			return nil, h(fmt.Errorf("line %q has %v fields", s.Text(), len(fields)))
+		default:
+			return nil, h(fmt.Errorf("unknown genetic map format %q; want one of %v", format, strings.Join(GeneticMapFormats, ", ")))
+		}
+
+		bp, e := strconv.ParseFloat(bpField, 64)
+		if e != nil && format == "hapmap" && len(points) == 0 {
+			continue
+		}
+		if e != nil { return nil, h(e) }
+		cm, e := strconv.ParseFloat(cmField, 64)
+		if e != nil { return nil, h(e) }
+		if bp < 0 {
+			continue
+		}
+		points[c] = append(points[c], mapPoint{bp: bp, cm: cm})
+	}
+	if e := s.Err(); e != nil {
+		return nil, h(e)
+	}
+
+	m := &GeneticMap{chroms: map[string]*chromMap{}}
+	for c, ps := range points {
+		cm, e := newChromMap(ps)
+		if e != nil {
+			return nil, h(fmt.Errorf("chromosome %v: %w", c, e))
+		}
+		m.chroms[c] = cm
+	}
+	return m, nil
+}
+
+// LoadGeneticMap reads a genetic map file. An empty format means plink for
+// paths ending in .map and hapmap otherwise.
+func LoadGeneticMap(path string, format string, chrom string) (*GeneticMap, error) {
+	if format == "" {
+		format = "hapmap"
+		if strings.HasSuffix(path, ".map") {
+			format = "plink"
+		}
+	}
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, fmt.Errorf("LoadGeneticMap: %w", e)
+	}
+	defer f.Close()
+	return ReadGeneticMap(f, format, chrom)
+}
+
+func newChromMap(ps []mapPoint) (*chromMap, error) {
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].bp < ps[j].bp })
+	c := &chromMap{}
+	for i, p := range ps {
+		if i > 0 && p.cm < ps[i - 1].cm {
+			return nil, fmt.Errorf("cM decreases from %v at %v bp to %v at %v bp", ps[i - 1].cm, ps[i - 1].bp, p.cm, p.bp)
+		}
+		if i > 0 && p.bp == ps[i - 1].bp {
+			if p.cm != ps[i - 1].cm {
+				return nil, fmt.Errorf("two positions in cM at %v bp", p.bp)
+			}
+			continue
+		}
+		c.bp = append(c.bp, p.bp)
+		c.cm = append(c.cm, p.cm)
+	}
+	if n := len(c.bp); n > 1 {
+		c.rate = (c.cm[n - 1] - c.cm[0]) / (c.bp[n - 1] - c.bp[0])
+	}
+	return c, nil
+}
+
+func (c *chromMap) toCM(bp float64) float64 {
+	n := len(c.bp)
+	i := sort.SearchFloat64s(c.bp, bp)
+	var cm float64
+	switch {
+	case i < n && c.bp[i] == bp:
+		cm = c.cm[i]
+	case i == 0:
+		cm = c.cm[0] - c.rate * (c.bp[0] - bp)
+	case i == n:
+		cm = c.cm[n - 1] + c.rate * (bp - c.bp[n - 1])
+	default:
+		frac := (bp - c.bp[i - 1]) / (c.bp[i] - c.bp[i - 1])
+		cm = c.cm[i - 1] + frac * (c.cm[i] - c.cm[i - 1])
+	}
+	return math.Max(cm, 0)
+}
+
+// toBP returns the smallest position at or beyond which toCM is at least
+// cm.
+func (c *chromMap) toBP(cm float64) float64 {
+	if cm <= 0 {
+		return 0
+	}
+	n := len(c.cm)
+	j := sort.SearchFloat64s(c.cm, cm)
+	var bp float64
+	switch {
+	case j == 0:
+		if c.rate == 0 {
+			return 0
+		}
+		bp = c.bp[0] - (c.cm[0] - cm) / c.rate
+	case j == n:
+		if c.rate == 0 {
+			return c.bp[n - 1]
+		}
+		bp = c.bp[n - 1] + (cm - c.cm[n - 1]) / c.rate
+	default:
+		frac := (cm - c.cm[j - 1]) / (c.cm[j] - c.cm[j - 1])
+		bp = c.bp[j - 1] + frac * (c.bp[j] - c.bp[j - 1])
+	}
+	return math.Max(bp, 0)
+}
+
+func (m *GeneticMap) HasChrom(chrom string) bool {
+	_, ok := m.chroms[chrom]
+	return ok
+}
+
+// CM returns the genetic position of bp, or NaN if the map has no points
+// on chrom.
+func (m *GeneticMap) CM(chrom string, bp float64) float64 {
+	c, ok := m.chroms[chrom]
+	if !ok {
+		return math.NaN()
+	}
+	return c.toCM(bp)
+}
+
+// BP returns the smallest physical position whose genetic position is at
+// least cm, or NaN if the map has no points on chrom.
+func (m *GeneticMap) BP(chrom string, cm float64) float64 {
+	c, ok := m.chroms[chrom]
+	if !ok {
+		return math.NaN()
+	}
+	return c.toBP(cm)
+}
+
+// GeneticScanner converts the coordinates of its input to cM, so that a
+// Slider over it makes windows of fixed genetic length. Entries are at
+// least GeneticEpsilon cM wide. Scan stops with an error at an entry on a
+// chromosome missing from the map.
+type GeneticScanner struct {
+	Map *GeneticMap
+	in BedOutputScanner
+	cur BedEntry
+	LastErr error
+}
+
+func NewGeneticScanner(in BedOutputScanner, m *GeneticMap) *GeneticScanner {
+	return &GeneticScanner{Map: m, in: in}
+}
+
+func (s *GeneticScanner) Scan() bool {
+	if s.LastErr != nil || !s.in.Scan() {
+		return false
+	}
+	b := s.in.Entry()
+	if !s.Map.HasChrom(b.Chrom) {
+		s.LastErr = fmt.Errorf("GeneticScanner: chromosome %q is not in the genetic map", b.Chrom)
+		return false
+	}
+	b.Left = s.Map.CM(b.Chrom, b.Left)
+	b.Right = math.Max(s.Map.CM(b.Chrom, b.Right), b.Left + GeneticEpsilon)
+	s.cur = b
+	return true
+}
+
+func (s *GeneticScanner) Entry() BedEntry {
+	return s.cur
+}
+
+func (s *GeneticScanner) Error() error {
+	if s.LastErr != nil {
+		return s.LastErr
+	}
+	return scanErr(s.in)
+}
+
+// GeneticFields records the genetic boundaries of a window. Inner is the
+// window's original Other, whose columns are written first.
+type GeneticFields struct {
+	StartCM float64
+	EndCM float64
+	Inner interface{}
+}
+
+func (f GeneticFields) Columns() []string {
+	names, _ := ExtraColumns(BedEntry{Other: f.Inner})
+	return append(names, "start_cm", "end_cm")
+}
+
+func (f GeneticFields) Values() []interface{} {
+	_, vals := ExtraColumns(BedEntry{Other: f.Inner})
+	return append(vals, f.StartCM, f.EndCM)
+}
+
+// GeneticWindows runs slider over in with size and step in cM, and reports
+// each window with its physical boundaries in bp and GeneticFields in
+// Other. Statistics that use lengths, such as bp covered or per-bp
+// diversity, see them in cM. Windows on chromosomes missing from the map,
+// such as the empty window a Slider reports for empty input, are dropped.
+func GeneticWindows(in *GeneticScanner, size float64, step float64, slider func(in BedOutputScanner, size, step float64) <-chan BedEntry) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+	wins := slider(in, size, step)
+
+	go func() {
+		for w := range wins {
+			if !in.Map.HasChrom(w.Chrom) {
+				continue
+			}
+			f := GeneticFields{StartCM: w.Left, EndCM: w.Right, Inner: w.Other}
+			w.Left = in.Map.BP(w.Chrom, f.StartCM)
+			w.Right = in.Map.BP(w.Chrom, f.EndCM)
+			w.Other = f
+			out <- w
+		}
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,99 @@
+package slide
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const testPlinkMap = `chr1	rs1	0	1000
+chr1	rs2	1	2000
+chr1	rs3	1	3000
+chr1	rs4	3	4000
+chr2	rs5	0.5	-1
+`
+
+func TestGeneticMap(t *testing.T) {
+	m, e := ReadGeneticMap(strings.NewReader(testPlinkMap), "plink", "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if m.HasChrom("chr2") {
+		t.Errorf("marker with negative position was kept")
+	}
+	for _, c := range []struct{ bp, cm float64 }{
+		{1000, 0}, {1500, 0.5}, {2500, 1}, {3500, 2}, {5000, 4}, {0, 0},
+	} {
+		if got := m.CM("chr1", c.bp); math.Abs(got - c.cm) > 1e-12 {
+			t.Errorf("CM(%v) = %v, want %v", c.bp, got, c.cm)
+		}
+	}
+	// 1 cM is reached at 2000 bp and held until 3000 bp.
+	for _, c := range []struct{ cm, bp float64 }{
+		{0, 0}, {0.5, 1500}, {1, 2000}, {2, 3500}, {4, 5000},
+	} {
+		if got := m.BP("chr1", c.cm); math.Abs(got - c.bp) > 1e-9 {
+			t.Errorf("BP(%v) = %v, want %v", c.cm, got, c.bp)
+		}
+	}
+	if !math.IsNaN(m.CM("chrX", 10)) {
+		t.Errorf("CM on a missing chromosome is not NaN")
+	}
+}
+
+func TestReadGeneticMapHapmap(t *testing.T) {
+	in := "Position(bp)\tRate(cM/Mb)\tMap(cM)\n100\t1\t0\n1100\t1\t0.001\n"
+	m, e := ReadGeneticMap(strings.NewReader(in), "hapmap", "chr3")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got := m.CM("chr3", 600); math.Abs(got - 0.0005) > 1e-12 {
+		t.Errorf("CM(600) = %v", got)
+	}
+	if _, e := ReadGeneticMap(strings.NewReader("chr1 1 0 5\nchr1 2 0 4\n"), "hapmap", ""); e == nil {
+		t.Errorf("decreasing map accepted")
+	}
+}
+
+func TestGeneticWindows(t *testing.T) {
+	m, e := ReadGeneticMap(strings.NewReader(testPlinkMap), "plink", "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	// Sites every 100 bp; those between 2000 and 3000 bp share 1 cM.
+	var sites []BedEntry
+	for pos := 1000.0; pos < 5000; pos += 100 {
+		sites = append(sites, BedEntry{Chrom: "chr1", Left: pos, Right: pos + 1, Val: 1})
+	}
+	s := NewGeneticScanner(NewBedSliceScanner(sites), m)
+	var got []BedEntry
+	for w := range GeneticWindows(s, 1, 1, func(in BedOutputScanner, size, step float64) <-chan BedEntry {
+		return SlidingAggregate(in, size, step, CountAggregator)
+	}) {
+		got = append(got, w)
+	}
+	if e := s.Error(); e != nil {
+		t.Fatal(e)
+	}
+	if len(got) < 2 {
+		t.Fatalf("got %v", got)
+	}
+	if got[0].Left != 0 || got[0].Right != 2000 || got[0].Val != 10 {
+		t.Errorf("window 0: %v", got[0])
+	}
+	// The second window spans the cold region, holding its 10 sites and the
+	// first 500 bp of the next interval.
+	if got[1].Left != 2000 || got[1].Right != 3500 || got[1].Val != 15 {
+		t.Errorf("window 1: %v", got[1])
+	}
+	if f := got[1].Other.(GeneticFields); f.StartCM != 1 || f.EndCM != 2 {
+		t.Errorf("window 1 fields: %+v", f)
+	}
+
+	bad := NewGeneticScanner(NewBedSliceScanner([]BedEntry{{Chrom: "chrX", Left: 1, Right: 2}}), m)
+	for bad.Scan() {
+	}
+	if bad.Error() == nil {
+		t.Errorf("missing chromosome not reported")
+	}
+}
@@ -1,13 +1,13 @@
 package slide
 
 import (
-	"bufio"
 	"encoding/csv"
 	"strings"
 	"math"
 	"strconv"
 	"fmt"
 	"io"
+	"net/url"
 	"regexp"
 )
 
@@ -21,27 +21,88 @@ var commentre = regexp.MustCompile(`^#`)
 
 type GffFields struct {
 	IsComment bool
+	Comment string
 	Source string
 	Type string
 	Score float64
 	Strand byte
 	Phase byte
 	AttributeNames []string
+	// Attributes holds unescaped values, except that the values of
+	// multi-valued attributes such as Parent are joined by commas with
+	// their own commas and percent signs still escaped; see GffValues.
 	Attributes map[string]string
 }
 
+// Columns and Values write the GFF columns after the coordinates, so that
+// windows read from GFF keep their type and attributes in the output.
+func (g GffFields) Columns() []string {
+	return []string{"source", "type", "score", "strand", "phase", "attributes"}
+}
+
+func (g GffFields) Values() []interface{} {
+	if g.IsComment {
+		return []interface{}{"", "", math.NaN(), "", "", ""}
+	}
+	return []interface{}{g.Source, g.Type, g.Score, gffByte(g.Strand), gffByte(g.Phase), FormatGffAttributes(g.AttributeNames, g.Attributes)}
+}
+
 func GffComment() BedEntry {
+	return GffCommentLine("")
+}
+
+func GffCommentLine(text string) BedEntry {
 	return BedEntry{
 		Other: GffFields {
 			IsComment: true,
+			Comment: text,
 		},
 	}
 }
 
+func gffUnescape(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	u, e := url.PathUnescape(s)
+	if e != nil {
+		return s
+	}
+	return u
+}
+
+var gffListEscaper = strings.NewReplacer("%", "%25", ",", "%2C")
+
+// gffAttributeValue unescapes the raw value of attribute name. Multi-valued
+// attributes are split on their unescaped commas first.
+func gffAttributeValue(name, raw string) string {
+	if !gffMultiValued[name] {
+		return gffUnescape(raw)
+	}
+	parts := strings.Split(raw, ",")
+	for i, p := range parts {
+		parts[i] = gffListEscaper.Replace(gffUnescape(p))
+	}
+	return strings.Join(parts, ",")
+}
+
+// GffValues returns the values of a multi-valued attribute such as Parent.
+func GffValues(val string) []string {
+	parts := strings.Split(val, ",")
+	for i, p := range parts {
+		parts[i] = gffUnescape(p)
+	}
+	return parts
+}
+
 func ParseGffAttributes(field string) ([]string, map[string]string, error) {
 	var names []string
 	vals := map[string]string{}
 
+	if field == "." {
+		return names, vals, nil
+	}
+
 	fields := strings.Split(field, ";")
 	for _, f := range fields {
 		if len(f) < 1 {
@@ -49,11 +110,12 @@ func ParseGffAttributes(field string) ([]string, map[string]string, error) {
 		}
 		eq := strings.Split(f, "=")
 		if len(eq) != 2 {
-			names = append(names, f)
+			names = append(names, gffUnescape(f))
 			continue
 		}
-		names = append(names, eq[0])
-		vals[eq[0]] = eq[1]
+		name := gffUnescape(eq[0])
+		names = append(names, name)
+		vals[name] = gffAttributeValue(name, eq[1])
 	}
 	return names, vals, nil
 }
@@ -62,7 +124,7 @@ func ParseGffLine(line []string) (BedEntry, error) {
 	h := handle("ParseGffLine: %w")
 
 	if len(line) > 0 && commentre.MatchString(line[0]) {
-		return GffComment(), nil
+		return GffCommentLine(strings.Join(line, "\t")), nil
 	}
 
 	if len(line) != 9 {
@@ -201,35 +263,33 @@ func SlidingGffBpCovered(in BedOutputScanner, size float64, step float64) <-chan
 	return out
 }
 
+// WriteEntry writes b as a TSV line with DefaultWriterOptions, including
+// any columns its Other adds.
 func WriteEntry(w io.Writer, b BedEntry) error {
-	_, e := fmt.Fprintf(w, "%v\t%d\t%d\t%v\n", b.Chrom, int(b.Left), int(b.Right), b.Val)
+	_, e := fmt.Fprintf(w, "%s\n", strings.Join(DefaultWriterOptions().Fields(b), "\t"))
 	return e
 }
 
 func SlidingGffEntryCountFull(inconn io.Reader, outconn io.Writer, size float64, step float64) error {
+	if e := CheckWindow(size, step); e != nil {
+		return e
+	}
 	b := NewGffScanner(inconn)
-	w := bufio.NewWriter(outconn)
-	defer w.Flush()
-
-	slid := SlidingGffEntryCount(b, size, step)
-
-	for entry := range slid {
-		e := WriteEntry(w, entry)
-		if e != nil { return e }
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingGffEntryCount(b, size, step))); e != nil {
+		return e
 	}
-	return nil
+	return b.Error()
 }
 
 func SlidingGffBpCoveredFull(inconn io.Reader, outconn io.Writer, size float64, step float64) error {
+	if e := CheckWindow(size, step); e != nil {
+		return e
+	}
 	b := NewGffScanner(inconn)
-	w := bufio.NewWriter(outconn)
-	defer w.Flush()
-
-	slid := SlidingGffBpCovered(b, size, step)
-
-	for entry := range slid {
-		e := WriteEntry(w, entry)
-		if e != nil { return e }
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingGffBpCovered(b, size, step))); e != nil {
+		return e
 	}
-	return nil
+	return b.Error()
 }
@@ -0,0 +1,70 @@
+package slide
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var roundTripGff = `##gff-version 3
+#a comment	with a tab
+chr1	src	gene	11	100	.	+	.	ID=g1;Name=a%3Bb%3Dc;Note=50%25 done;flag
+chr1	.	exon	11	20	3.5	-	0	Parent=t1,t2;Note=x,y;product=1%2C2-diol
+chr1	.	exon	21	30	.	-	.	Parent=a%2Cb
+chr%091	src	region	1	5	.	.	.	.
+`
+
+func TestGffRoundTrip(t *testing.T) {
+	var out strings.Builder
+	if err := WriteGff(&out, NewGffScanner(strings.NewReader(roundTripGff))); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != roundTripGff {
+		t.Errorf("out\n%v\n!= expect\n%v", out.String(), roundTripGff)
+	}
+
+	s := NewGffScanner(strings.NewReader(roundTripGff))
+	for s.Scan() {
+		g := s.Entry().Other.(GffFields)
+		if g.Type == "gene" && g.Attributes["Name"] != "a;b=c" {
+			t.Errorf("unescaped Name %q != %q", g.Attributes["Name"], "a;b=c")
+		}
+		if g.Type == "exon" && s.Entry().Left == 10 && g.Attributes["product"] != "1,2-diol" {
+			t.Errorf("unescaped product %q != %q", g.Attributes["product"], "1,2-diol")
+		}
+		if g.Type == "exon" && s.Entry().Left == 20 && !reflect.DeepEqual(GffValues(g.Attributes["Parent"]), []string{"a,b"}) {
+			t.Errorf("parents %q != %q", GffValues(g.Attributes["Parent"]), []string{"a,b"})
+		}
+		if g.Type == "gene" && !reflect.DeepEqual(GffValues(g.Attributes["Note"]), []string{"50% done"}) {
+			t.Errorf("Note %q != %q", GffValues(g.Attributes["Note"]), []string{"50% done"})
+		}
+		if g.Type == "region" && s.Entry().Chrom != "chr%091" {
+			t.Errorf("chrom %q != %q", s.Entry().Chrom, "chr%091")
+		}
+	}
+}
+
+func TestGffWriterHeader(t *testing.T) {
+	var out strings.Builder
+	w := NewGffWriter(&out)
+	b := BedEntry{Chrom: "chr1", Left: 0, Right: 10, Other: GffFields{Type: "gene", Strand: '+', Phase: '.', Attributes: map[string]string{"ID": "x"}}}
+	if err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	expect := "##gff-version 3\nchr1\t.\tgene\t1\t10\t0\t+\t.\tID=x\n"
+	if out.String() != expect {
+		t.Errorf("out %q != expect %q", out.String(), expect)
+	}
+}
+
+func TestWriteEntryOther(t *testing.T) {
+	var out strings.Builder
+	b := BedEntry{Chrom: "chr1", Left: 0, Right: 10, Val: 2, Other: SpanFields{Peak: 2, Mean: 1.5, Hits: 2}}
+	if err := WriteEntry(&out, b); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "chr1\t0\t10\t2\t2\t1.5\t2\n"; out.String() != expect {
+		t.Errorf("out %q != expect %q", out.String(), expect)
+	}
+}
@@ -0,0 +1,408 @@
+package slide
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+type GffFeature struct {
+	Entry BedEntry
+	Fields GffFields
+	ID string
+	Parents []*GffFeature
+	Children []*GffFeature
+}
+
+// GffGraph links GFF features through their ID and Parent attributes.
+type GffGraph struct {
+	Features []*GffFeature
+	ByID map[string]*GffFeature
+	Chroms []string
+}
+
+func BuildGffGraph(in BedOutputScanner) (*GffGraph, error) {
+	h := handle("BuildGffGraph: %w")
+
+	g := &GffGraph{ByID: map[string]*GffFeature{}}
+	seenChroms := map[string]struct{}{}
+	var parentIDs [][]string
+
+	for in.Scan() {
+		b := in.Entry()
+		fields, ok := b.Other.(GffFields)
+		if !ok {
+			return nil, h(fmt.Errorf("entry %v has no GffFields", b))
+		}
+		if fields.IsComment {
+			continue
+		}
+
+		f := &GffFeature{Entry: b, Fields: fields, ID: fields.Attributes["ID"]}
+		g.Features = append(g.Features, f)
+		if f.ID != "" {
+			if _, ok := g.ByID[f.ID]; !ok {
+				g.ByID[f.ID] = f
+			}
+		}
+
+		var pids []string
+		if p := fields.Attributes["Parent"]; p != "" {
+			pids = GffValues(p)
+		}
+		parentIDs = append(parentIDs, pids)
+
+		if _, ok := seenChroms[b.Chrom]; !ok {
+			seenChroms[b.Chrom] = struct{}{}
+			g.Chroms = append(g.Chroms, b.Chrom)
+		}
+	}
+	if e := scanErr(in); e != nil {
+		return nil, h(e)
+	}
+
+	for i, f := range g.Features {
+		for _, pid := range parentIDs[i] {
+			p, ok := g.ByID[pid]
+			if !ok || p == f {
+				continue
+			}
+			f.Parents = append(f.Parents, p)
+			p.Children = append(p.Children, f)
+		}
+	}
+	for _, f := range g.Features {
+		sort.SliceStable(f.Children, func(i, j int) bool {
+			return f.Children[i].Entry.Left < f.Children[j].Entry.Left
+		})
+	}
+	return g, nil
+}
+
+func (f *GffFeature) ChildrenOfType(types ...string) []*GffFeature {
+	var out []*GffFeature
+	for _, c := range f.Children {
+		for _, t := range types {
+			if c.Fields.Type == t {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// CountDescendants counts the distinct features of type typ below f, so
+// CountDescendants(gene, "exon") gives the exon count of a gene.
+func (g *GffGraph) CountDescendants(f *GffFeature, typ string) int {
+	seen := map[*GffFeature]struct{}{}
+	var walk func(*GffFeature)
+	walk = func(f *GffFeature) {
+		for _, c := range f.Children {
+			if _, ok := seen[c]; ok {
+				continue
+			}
+			seen[c] = struct{}{}
+			walk(c)
+		}
+	}
+	walk(f)
+
+	count := 0
+	for c := range seen {
+		if c.Fields.Type == typ {
+			count++
+		}
+	}
+	return count
+}
+
+// Exons returns the exons of a transcript, falling back to its CDS parts
+// when no exons are annotated.
+func (f *GffFeature) Exons() []*GffFeature {
+	exons := f.ChildrenOfType("exon")
+	if len(exons) == 0 {
+		exons = f.ChildrenOfType("CDS")
+	}
+	return exons
+}
+
+// Transcripts returns every feature with exon or CDS children.
+func (g *GffGraph) Transcripts() []*GffFeature {
+	var out []*GffFeature
+	for _, f := range g.Features {
+		if len(f.Exons()) > 0 {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func geneOf(t *GffFeature) *GffFeature {
+	if len(t.Parents) > 0 {
+		return t.Parents[0]
+	}
+	return t
+}
+
+// Genes returns all features of type gene, plus the top-level features of
+// transcripts that have no gene above them.
+func (g *GffGraph) Genes() []*GffFeature {
+	seen := map[*GffFeature]struct{}{}
+	var out []*GffFeature
+	add := func(f *GffFeature) {
+		if _, ok := seen[f]; ok {
+			return
+		}
+		seen[f] = struct{}{}
+		out = append(out, f)
+	}
+
+	for _, f := range g.Features {
+		if f.Fields.Type == "gene" {
+			add(f)
+		}
+	}
+	for _, t := range g.Transcripts() {
+		add(geneOf(t))
+	}
+	g.sortFeatures(out)
+	return out
+}
+
+func (g *GffGraph) chromOrder() map[string]int {
+	order := map[string]int{}
+	for i, c := range g.Chroms {
+		order[c] = i
+	}
+	return order
+}
+
+func (g *GffGraph) sortFeatures(fs []*GffFeature) {
+	order := g.chromOrder()
+	sort.SliceStable(fs, func(i, j int) bool {
+		a, b := fs[i].Entry, fs[j].Entry
+		if a.Chrom != b.Chrom {
+			return order[a.Chrom] < order[b.Chrom]
+		}
+		return a.Left < b.Left
+	})
+}
+
+func (g *GffGraph) sortedScanner(entries []BedEntry) *BedSliceScanner {
+	order := g.chromOrder()
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Chrom != b.Chrom {
+			oa, aok := order[a.Chrom]
+			ob, bok := order[b.Chrom]
+			if aok != bok {
+				return aok
+			}
+			if !aok {
+				return a.Chrom < b.Chrom
+			}
+			return oa < ob
+		}
+		if a.Left != b.Left {
+			return a.Left < b.Left
+		}
+		return a.Right < b.Right
+	})
+	return NewBedSliceScanner(entries)
+}
+
+func derivedEntry(chrom string, left, right float64, typ string, from *GffFeature, attr string, val string) BedEntry {
+	var g GffFields
+	g.Type = typ
+	g.Score = math.NaN()
+	g.Strand = '.'
+	g.Phase = '.'
+	g.Source = "."
+	if from != nil {
+		g.Source = from.Fields.Source
+		g.Strand = from.Fields.Strand
+	}
+	g.Attributes = map[string]string{}
+	if val != "" {
+		if gffMultiValued[attr] {
+			val = gffListEscaper.Replace(val)
+		}
+		g.AttributeNames = []string{attr}
+		g.Attributes[attr] = val
+	}
+	return BedEntry{Chrom: chrom, Left: left, Right: right, Other: g}
+}
+
+type span1d struct {
+	left float64
+	right float64
+}
+
+func mergeSpans(spans []span1d) []span1d {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].left < spans[j].left })
+	var out []span1d
+	for _, s := range spans {
+		if len(out) > 0 && s.left <= out[len(out)-1].right {
+			if s.right > out[len(out)-1].right {
+				out[len(out)-1].right = s.right
+			}
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func exonSpans(t *GffFeature) []span1d {
+	var spans []span1d
+	for _, e := range t.Exons() {
+		spans = append(spans, span1d{e.Entry.Left, e.Entry.Right})
+	}
+	return mergeSpans(spans)
+}
+
+// Introns returns the gaps between consecutive exons of each transcript.
+func (g *GffGraph) Introns() *BedSliceScanner {
+	var out []BedEntry
+	for _, t := range g.Transcripts() {
+		spans := exonSpans(t)
+		for i := 1; i < len(spans); i++ {
+			out = append(out, derivedEntry(t.Entry.Chrom, spans[i-1].right, spans[i].left, "intron", t, "Parent", t.ID))
+		}
+	}
+	return g.sortedScanner(out)
+}
+
+// UTRs returns the exonic parts of coding transcripts lying outside their
+// CDS, typed five_prime_UTR or three_prime_UTR according to strand.
+func (g *GffGraph) UTRs() *BedSliceScanner {
+	var out []BedEntry
+	for _, t := range g.Transcripts() {
+		cds := t.ChildrenOfType("CDS")
+		if len(cds) == 0 {
+			continue
+		}
+		cdsLeft, cdsRight := math.Inf(1), math.Inf(-1)
+		for _, c := range cds {
+			cdsLeft = math.Min(cdsLeft, c.Entry.Left)
+			cdsRight = math.Max(cdsRight, c.Entry.Right)
+		}
+
+		leftType, rightType := "five_prime_UTR", "three_prime_UTR"
+		if t.Fields.Strand == '-' {
+			leftType, rightType = rightType, leftType
+		}
+
+		for _, s := range exonSpans(t) {
+			if s.left < cdsLeft {
+				out = append(out, derivedEntry(t.Entry.Chrom, s.left, math.Min(s.right, cdsLeft), leftType, t, "Parent", t.ID))
+			}
+			if s.right > cdsRight {
+				out = append(out, derivedEntry(t.Entry.Chrom, math.Max(s.left, cdsRight), s.right, rightType, t, "Parent", t.ID))
+			}
+		}
+	}
+	return g.sortedScanner(out)
+}
+
+func (g *GffGraph) mergedGenes() map[string][]BedEntry {
+	byChrom := map[string][]*GffFeature{}
+	for _, gene := range g.Genes() {
+		byChrom[gene.Entry.Chrom] = append(byChrom[gene.Entry.Chrom], gene)
+	}
+
+	out := map[string][]BedEntry{}
+	for chrom, genes := range byChrom {
+		var merged []BedEntry
+		var ids []string
+		flush := func() {
+			if len(merged) > 0 {
+				last := &merged[len(merged)-1]
+				last.Other = derivedEntry(chrom, last.Left, last.Right, "merged_gene", nil, "genes", strings.Join(ids, ",")).Other
+			}
+		}
+		for _, gene := range genes {
+			if len(merged) > 0 && gene.Entry.Left <= merged[len(merged)-1].Right {
+				last := &merged[len(merged)-1]
+				last.Right = math.Max(last.Right, gene.Entry.Right)
+				ids = append(ids, gene.ID)
+				continue
+			}
+			flush()
+			merged = append(merged, BedEntry{Chrom: chrom, Left: gene.Entry.Left, Right: gene.Entry.Right})
+			ids = []string{gene.ID}
+		}
+		flush()
+		out[chrom] = merged
+	}
+	return out
+}
+
+// MergedGenes returns the union of gene bodies on each chromosome. The
+// genes attribute lists the IDs of the genes merged into each entry.
+func (g *GffGraph) MergedGenes() *BedSliceScanner {
+	var out []BedEntry
+	for _, merged := range g.mergedGenes() {
+		out = append(out, merged...)
+	}
+	return g.sortedScanner(out)
+}
+
+// Intergenic returns the regions between merged gene bodies. When
+// chromLens holds the length of a chromosome the region after its last
+// gene is included, as are chromosomes with no genes at all.
+func (g *GffGraph) Intergenic(chromLens map[string]float64) *BedSliceScanner {
+	var out []BedEntry
+	merged := g.mergedGenes()
+	for chrom, genes := range merged {
+		prev := 0.0
+		for _, gene := range genes {
+			if gene.Left > prev {
+				out = append(out, derivedEntry(chrom, prev, gene.Left, "intergenic_region", nil, "", ""))
+			}
+			prev = math.Max(prev, gene.Right)
+		}
+		if l, ok := chromLens[chrom]; ok && l > prev {
+			out = append(out, derivedEntry(chrom, prev, l, "intergenic_region", nil, "", ""))
+		}
+	}
+	for chrom, l := range chromLens {
+		if _, ok := merged[chrom]; !ok && l > 0 {
+			out = append(out, derivedEntry(chrom, 0, l, "intergenic_region", nil, "", ""))
+		}
+	}
+	return g.sortedScanner(out)
+}
+
+func exonLength(t *GffFeature) float64 {
+	total := 0.0
+	for _, s := range exonSpans(t) {
+		total += s.right - s.left
+	}
+	return total
+}
+
+// LongestIsoforms returns, for each gene, the transcript with the greatest
+// total exon length.
+func (g *GffGraph) LongestIsoforms() *BedSliceScanner {
+	best := map[*GffFeature]*GffFeature{}
+	var genes []*GffFeature
+	for _, t := range g.Transcripts() {
+		gene := geneOf(t)
+		cur, ok := best[gene]
+		if !ok {
+			genes = append(genes, gene)
+		}
+		if !ok || exonLength(t) > exonLength(cur) {
+			best[gene] = t
+		}
+	}
+
+	var out []BedEntry
+	for _, gene := range genes {
+		out = append(out, best[gene].Entry)
+	}
+	return g.sortedScanner(out)
+}
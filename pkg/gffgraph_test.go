@@ -0,0 +1,74 @@
+package slide
+
+import (
+	"strings"
+	"testing"
+)
+
+var graphGff = `##gff-version 3
+chr1	t	gene	11	100	.	+	.	ID=g1
+chr1	t	mRNA	11	100	.	+	.	ID=t1;Parent=g1
+chr1	t	exon	11	20	.	+	.	Parent=t1
+chr1	t	exon	41	60	.	+	.	Parent=t1
+chr1	t	exon	81	100	.	+	.	Parent=t1
+chr1	t	CDS	16	20	.	+	0	Parent=t1
+chr1	t	CDS	41	60	.	+	1	Parent=t1
+chr1	t	CDS	81	90	.	+	0	Parent=t1
+chr1	t	mRNA	11	60	.	+	.	ID=t2;Parent=g1
+chr1	t	exon	11	20	.	+	.	Parent=t2
+chr1	t	exon	41	60	.	+	.	Parent=t2
+chr1	t	gene	91	150	.	-	.	ID=g2
+chr1	t	gene	201	300	.	-	.	ID=g3
+`
+
+type span3 struct {
+	Chrom string
+	Left float64
+	Right float64
+}
+
+func collectSpans(s BedOutputScanner) []span3 {
+	var out []span3
+	for s.Scan() {
+		e := s.Entry()
+		out = append(out, span3{e.Chrom, e.Left, e.Right})
+	}
+	return out
+}
+
+func TestGffGraph(t *testing.T) {
+	g, err := BuildGffGraph(NewGffScanner(strings.NewReader(graphGff)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := g.CountDescendants(g.ByID["g1"], "exon"); n != 5 {
+		t.Errorf("exon count %v != 5", n)
+	}
+
+	tests := []struct {
+		Name string
+		Scanner BedOutputScanner
+		Expect []span3
+	}{
+		{"introns", g.Introns(), []span3{{"chr1", 20, 40}, {"chr1", 20, 40}, {"chr1", 60, 80}}},
+		{"utrs", g.UTRs(), []span3{{"chr1", 10, 15}, {"chr1", 90, 100}}},
+		{"merged", g.MergedGenes(), []span3{{"chr1", 10, 150}, {"chr1", 200, 300}}},
+		{"intergenic", g.Intergenic(map[string]float64{"chr1": 400, "chr2": 50}), []span3{{"chr1", 0, 10}, {"chr1", 150, 200}, {"chr1", 300, 400}, {"chr2", 0, 50}}},
+		{"longest", g.LongestIsoforms(), []span3{{"chr1", 10, 100}}},
+	}
+
+	for _, test := range tests {
+		out := collectSpans(test.Scanner)
+		if len(out) != len(test.Expect) {
+			t.Errorf("%v: out %v != expect %v", test.Name, out, test.Expect)
+			continue
+		}
+		for i := range out {
+			if out[i] != test.Expect[i] {
+				t.Errorf("%v: out %v != expect %v", test.Name, out, test.Expect)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package slide
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gffEscape percent-encodes the characters in s that cannot appear
+// literally in a GFF3 column, plus any in extra.
+func gffEscape(s string, extra string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7f || c == '%' || strings.IndexByte(extra, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// gffColumn writes a seqid, source or type column. ParseGffLine does not
+// unescape these, so only characters that would break the line are escaped.
+func gffColumn(s string) string {
+	if s == "" {
+		return "."
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// gffMultiValued lists the attributes that GFF3 allows to hold several
+// comma-separated values.
+var gffMultiValued = map[string]bool{
+	"Parent": true,
+	"Alias": true,
+	"Note": true,
+	"Dbxref": true,
+	"Ontology_term": true,
+}
+
+func gffValue(name, val string) string {
+	if !gffMultiValued[name] {
+		return gffEscape(val, ";=&,")
+	}
+	vals := GffValues(val)
+	for i, v := range vals {
+		vals[i] = gffEscape(v, ";=&,")
+	}
+	return strings.Join(vals, ",")
+}
+
+func gffByte(c byte) string {
+	if c == 0 {
+		return "."
+	}
+	return string(c)
+}
+
+// FormatGffAttributes writes attributes in the order of names, followed by
+// any attributes missing from names in sorted order. Commas are escaped,
+// except in the attributes GFF3 allows to hold several values, such as
+// Parent=a,b, where they separate the values.
+func FormatGffAttributes(names []string, vals map[string]string) string {
+	var fields []string
+	written := map[string]struct{}{}
+	for _, name := range names {
+		if _, ok := written[name]; ok {
+			continue
+		}
+		written[name] = struct{}{}
+		val, ok := vals[name]
+		if !ok {
+			fields = append(fields, gffEscape(name, ";=&"))
+			continue
+		}
+		fields = append(fields, gffEscape(name, ";=&,") + "=" + gffValue(name, val))
+	}
+
+	var rest []string
+	for name := range vals {
+		if _, ok := written[name]; !ok {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		fields = append(fields, gffEscape(name, ";=&,") + "=" + gffValue(name, vals[name]))
+	}
+
+	if len(fields) == 0 {
+		return "."
+	}
+	return strings.Join(fields, ";")
+}
+
+// FormatGffLine is the inverse of ParseGffLine, restoring 1-based
+// coordinates. Comment entries are returned as their original text.
+func FormatGffLine(b BedEntry) ([]string, error) {
+	g, ok := b.Other.(GffFields)
+	if !ok {
+		return nil, fmt.Errorf("FormatGffLine: entry %v has no GffFields", b)
+	}
+	if g.IsComment {
+		return []string{g.Comment}, nil
+	}
+
+	score := "."
+	if !math.IsNaN(g.Score) {
+		score = strconv.FormatFloat(g.Score, 'g', -1, 64)
+	}
+
+	return []string{
+		gffColumn(b.Chrom),
+		gffColumn(g.Source),
+		gffColumn(g.Type),
+		strconv.FormatInt(int64(b.Left) + 1, 10),
+		strconv.FormatInt(int64(b.Right), 10),
+		score,
+		gffByte(g.Strand),
+		gffByte(g.Phase),
+		FormatGffAttributes(g.AttributeNames, g.Attributes),
+	}, nil
+}
+
+func WriteGffEntry(w io.Writer, b BedEntry) error {
+	line, e := FormatGffLine(b)
+	if e != nil {
+		return e
+	}
+	_, e = fmt.Fprintf(w, "%s\n", strings.Join(line, "\t"))
+	return e
+}
+
+// GffWriter writes entries as GFF3, emitting a ##gff-version 3 header
+// unless the first entry written is already one.
+type GffWriter struct {
+	w *bufio.Writer
+	started bool
+}
+
+func NewGffWriter(w io.Writer) *GffWriter {
+	return &GffWriter{w: bufio.NewWriter(w)}
+}
+
+func (w *GffWriter) Write(b BedEntry) error {
+	h := handle("GffWriter.Write: %w")
+	if !w.started {
+		w.started = true
+		g, ok := b.Other.(GffFields)
+		if !ok || !g.IsComment || !strings.HasPrefix(g.Comment, "##gff-version") {
+			if _, e := io.WriteString(w.w, "##gff-version 3\n"); e != nil {
+				return h(e)
+			}
+		}
+	}
+	if e := WriteGffEntry(w.w, b); e != nil {
+		return h(e)
+	}
+	return nil
+}
+
+func (w *GffWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func WriteGff(w io.Writer, in BedOutputScanner) error {
+	h := handle("WriteGff: %w")
+	gw := NewGffWriter(w)
+	for in.Scan() {
+		if e := gw.Write(in.Entry()); e != nil {
+			return h(e)
+		}
+	}
+	if e := scanErr(in); e != nil {
+		return h(e)
+	}
+	if e := gw.Flush(); e != nil {
+		return h(e)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package slide
+
+import (
+	"math"
+	"sort"
+)
+
+// IntervalIndex holds entries in memory for random-access overlap queries.
+// Each chromosome is an implicit augmented interval tree, as in cgranges:
+// the entries are sorted by Left and the tree is laid over the sorted
+// array, with each internal node storing the largest Right below it.
+type IntervalIndex struct {
+	chroms map[string]*chromIndex
+	n int
+}
+
+type chromIndex struct {
+	entries []BedEntry
+	// maxRight[i] is the largest Right in the subtree rooted at entry i.
+	maxRight []float64
+	// prefixMax[i] is the index of the entry with the largest Right among
+	// entries[:i+1], for nearest-neighbour lookups.
+	prefixMax []int
+	maxLevel int
+}
+
+// NewIntervalIndex reads all of in, which need not be sorted.
+func NewIntervalIndex(in BedOutputScanner) (*IntervalIndex, error) {
+	x := &IntervalIndex{chroms: map[string]*chromIndex{}}
+	for in.Scan() {
+		b := in.Entry()
+		c, ok := x.chroms[b.Chrom]
+		if !ok {
+			c = &chromIndex{}
+			x.chroms[b.Chrom] = c
+		}
+		c.entries = append(c.entries, b)
+		x.n++
+	}
+	if e := scanErr(in); e != nil {
+		return nil, handle("NewIntervalIndex: %w")(e)
+	}
+	for _, c := range x.chroms {
+		c.build()
+	}
+	return x, nil
+}
+
+// Len returns the number of entries in the index.
+func (x *IntervalIndex) Len() int {
+	return x.n
+}
+
+func (c *chromIndex) build() {
+	a := c.entries
+	sort.SliceStable(a, func(i, j int) bool { return a[i].Left < a[j].Left })
+	n := len(a)
+	c.maxRight = make([]float64, n)
+	c.prefixMax = make([]int, n)
+	for i := range a {
+		c.maxRight[i] = a[i].Right
+		c.prefixMax[i] = i
+		if i > 0 && a[c.prefixMax[i - 1]].Right >= a[i].Right {
+			c.prefixMax[i] = c.prefixMax[i - 1]
+		}
+	}
+	if n == 0 {
+		return
+	}
+
+	// Leaves are the even indices; a node at level k has index with k
+	// trailing ones. last tracks the largest Right of the rightmost,
+	// possibly incomplete, subtree at each level.
+	lastI := (n - 1) &^ 1
+	last := c.maxRight[lastI]
+	k := 1
+	for ; 1 << k <= n; k++ {
+		x := 1 << (k - 1)
+		for i := (x << 1) - 1; i < n; i += x << 2 {
+			el := c.maxRight[i - x]
+			er := last
+			if i + x < n {
+				er = c.maxRight[i + x]
+			}
+			c.maxRight[i] = math.Max(a[i].Right, math.Max(el, er))
+		}
+		if lastI >> k & 1 != 0 {
+			lastI -= x
+		} else {
+			lastI += x
+		}
+		if lastI < n && c.maxRight[lastI] > last {
+			last = c.maxRight[lastI]
+		}
+	}
+	c.maxLevel = k - 1
+}
+
+type indexFrame struct {
+	k, x int
+	leftDone bool
+}
+
+// each calls f for each entry overlapping [left, right), in order of Left.
+func (c *chromIndex) each(left, right float64, f func(i int)) {
+	a := c.entries
+	n := len(a)
+	if n == 0 {
+		return
+	}
+	var hits []int
+	stack := []indexFrame{{k: c.maxLevel, x: (1 << c.maxLevel) - 1}}
+	for len(stack) > 0 {
+		z := stack[len(stack) - 1]
+		stack = stack[:len(stack) - 1]
+		switch {
+		case z.k <= 3:
+			// Scan small subtrees directly.
+			i0 := z.x >> z.k << z.k
+			i1 := i0 + (1 << (z.k + 1)) - 1
+			if i1 > n {
+				i1 = n
+			}
+			for i := i0; i < i1 && a[i].Left < right; i++ {
+				if left < a[i].Right {
+					hits = append(hits, i)
+				}
+			}
+		case !z.leftDone:
+			y := z.x - (1 << (z.k - 1))
+			stack = append(stack, indexFrame{k: z.k, x: z.x, leftDone: true})
+			if y >= n || c.maxRight[y] > left {
+				stack = append(stack, indexFrame{k: z.k - 1, x: y})
+			}
+		case z.x < n && a[z.x].Left < right:
+			if left < a[z.x].Right {
+				hits = append(hits, z.x)
+			}
+			stack = append(stack, indexFrame{k: z.k - 1, x: z.x + (1 << (z.k - 1))})
+		}
+	}
+	sort.Ints(hits)
+	for _, i := range hits {
+		f(i)
+	}
+}
+
+// Overlaps returns the entries overlapping r, sorted by Left.
+func (x *IntervalIndex) Overlaps(r Region) []BedEntry {
+	c, ok := x.chroms[r.Chrom]
+	if !ok {
+		return nil
+	}
+	var out []BedEntry
+	c.each(r.Start, r.End, func(i int) { out = append(out, c.entries[i]) })
+	return out
+}
+
+// Count returns the number of entries overlapping r.
+func (x *IntervalIndex) Count(r Region) int {
+	c, ok := x.chroms[r.Chrom]
+	if !ok {
+		return 0
+	}
+	n := 0
+	c.each(r.Start, r.End, func(int) { n++ })
+	return n
+}
+
+// Nearest returns the entry closest to r on its chromosome and the number
+// of bases between them, negative if the entry is upstream of r and 0 if it
+// overlaps or touches r. Of several overlapping entries, the one with the
+// smallest Left is returned; of equally near neighbours, the upstream one.
+// ok is false if the chromosome has no entries.
+func (x *IntervalIndex) Nearest(r Region) (b BedEntry, distance float64, ok bool) {
+	c, found := x.chroms[r.Chrom]
+	if !found || len(c.entries) == 0 {
+		return BedEntry{}, math.NaN(), false
+	}
+	if hits := x.Overlaps(r); len(hits) > 0 {
+		return hits[0], 0, true
+	}
+
+	// With no overlaps, every entry starting before r.End ends by r.Start.
+	a := c.entries
+	j := sort.Search(len(a), func(i int) bool { return a[i].Left >= r.End })
+	query := BedEntry{Left: r.Start, Right: r.End}
+	switch {
+	case j == 0:
+		return a[0], pairDistance(query, a[0]), true
+	case j == len(a):
+		up := a[c.prefixMax[j - 1]]
+		return up, pairDistance(query, up), true
+	}
+	up, down := a[c.prefixMax[j - 1]], a[j]
+	du, dd := pairDistance(query, up), pairDistance(query, down)
+	if -du <= dd {
+		return up, du, true
+	}
+	return down, dd, true
+}
+
+// Aggregate reports agg over the indexed entries overlapping each entry of
+// windows, which may come in any order. Each window keeps its coordinates
+// and Other, with the aggregate in Val.
+func (x *IntervalIndex) Aggregate(windows BedOutputScanner, agg Aggregator) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for windows.Scan() {
+			win := windows.Entry()
+			items := x.Overlaps(Region{Chrom: win.Chrom, Start: win.Left, End: win.Right})
+			win.Val = agg(win, items)
+			out <- win
+		}
+		close(out)
+	}()
+	return out
+}
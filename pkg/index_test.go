@@ -0,0 +1,84 @@
+package slide
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestIntervalIndexOverlaps(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 2, 7, 16, 33, 500} {
+		var entries []BedEntry
+		for i := 0; i < n; i++ {
+			left := float64(r.Intn(10000))
+			length := float64(1 + r.Intn(50))
+			if r.Intn(20) == 0 {
+				length = float64(r.Intn(3000))
+			}
+			entries = append(entries, BedEntry{Chrom: "chr1", Left: left, Right: left + length, Val: float64(i)})
+		}
+		x, err := NewIntervalIndex(NewBedSliceScanner(entries))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.Len() != n {
+			t.Errorf("n %v: Len() = %v", n, x.Len())
+		}
+		for q := 0; q < 200; q++ {
+			left := float64(r.Intn(11000))
+			reg := Region{Chrom: "chr1", Start: left, End: left + float64(1 + r.Intn(200))}
+			want := map[float64]bool{}
+			for _, b := range entries {
+				if reg.Overlaps(b) {
+					want[b.Val] = true
+				}
+			}
+			got := map[float64]bool{}
+			for _, b := range x.Overlaps(reg) {
+				got[b.Val] = true
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("n %v, region %v: got %v, want %v", n, reg, got, want)
+			}
+			if c := x.Count(reg); c != len(want) {
+				t.Fatalf("n %v, region %v: Count() = %v, want %v", n, reg, c, len(want))
+			}
+
+			_, d, ok := x.Nearest(reg)
+			best := math.Inf(1)
+			for _, b := range entries {
+				best = math.Min(best, math.Abs(pairDistance(BedEntry{Left: reg.Start, Right: reg.End}, b)))
+			}
+			if ok != (n > 0) || (ok && math.Abs(d) != best) {
+				t.Fatalf("n %v, region %v: Nearest() distance %v, want %v", n, reg, d, best)
+			}
+		}
+	}
+}
+
+func TestIntervalIndexAggregate(t *testing.T) {
+	entries := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 10, Val: 1},
+		{Chrom: "chr1", Left: 5, Right: 15, Val: 3},
+		{Chrom: "chr2", Left: 0, Right: 10, Val: 5},
+	}
+	x, err := NewIntervalIndex(NewBedSliceScanner(entries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows := []BedEntry{
+		{Chrom: "chr2", Left: 0, Right: 100},
+		{Chrom: "chr1", Left: 8, Right: 9},
+		{Chrom: "chr1", Left: 12, Right: 20},
+		{Chrom: "chr3", Left: 0, Right: 20},
+	}
+	got := collectVals(NewBedEntryScanner(x.Aggregate(NewBedSliceScanner(windows), MeanAggregator)))
+	want := []float64{5, 2, 3, math.NaN()}
+	for i := range want {
+		if !sameFloat(got[i], want[i]) {
+			t.Errorf("window %v: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
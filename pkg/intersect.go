@@ -0,0 +1,180 @@
+package slide
+
+import (
+	"math"
+)
+
+// overlapper follows a sorted stream b alongside a sorted stream of query
+// entries, keeping the entries of b that may overlap the current query.
+// Both streams must be sorted by Left within each chromosome and keep each
+// chromosome's entries together, but the chromosomes may come in different
+// orders; entries of b on chromosomes that the queries have not reached yet
+// are buffered.
+type overlapper struct {
+	b BedOutputScanner
+	pending map[string][]BedEntry
+	finished map[string]bool
+	seen map[string]bool
+	active []BedEntry
+	next *BedEntry
+	done bool
+	chrom string
+	started bool
+	// upstream is the entry of b on the current chromosome with the
+	// greatest Right of those that ended before the current query.
+	upstream *BedEntry
+}
+
+func newOverlapper(b BedOutputScanner) *overlapper {
+	return &overlapper{
+		b: b,
+		pending: map[string][]BedEntry{},
+		finished: map[string]bool{},
+		seen: map[string]bool{},
+	}
+}
+
+// advance moves to query x, which must not start before the previous query
+// on its chromosome.
+func (o *overlapper) advance(x BedEntry) {
+	if !o.started || x.Chrom != o.chrom {
+		if o.started {
+			o.finished[o.chrom] = true
+		}
+		o.started = true
+		o.chrom = x.Chrom
+		o.active = o.pending[o.chrom]
+		o.upstream = nil
+		delete(o.pending, o.chrom)
+	}
+
+	// Read b until it is past x on this chromosome or has left it.
+	for !o.done {
+		if o.next == nil {
+			if !o.b.Scan() {
+				o.done = true
+				break
+			}
+			e := o.b.Entry()
+			o.next = &e
+		}
+		if o.next.Chrom == o.chrom {
+			if o.next.Left >= x.Right {
+				break
+			}
+			o.seen[o.chrom] = true
+			o.active = append(o.active, *o.next)
+			o.next = nil
+			continue
+		}
+		if o.seen[o.chrom] {
+			break
+		}
+		if !o.finished[o.next.Chrom] {
+			o.seen[o.next.Chrom] = true
+			o.pending[o.next.Chrom] = append(o.pending[o.next.Chrom], *o.next)
+		}
+		o.next = nil
+	}
+
+	kept := o.active[:0]
+	for _, y := range o.active {
+		if y.Right <= x.Left {
+			if o.upstream == nil || y.Right > o.upstream.Right {
+				y := y
+				o.upstream = &y
+			}
+			continue
+		}
+		kept = append(kept, y)
+	}
+	o.active = kept
+}
+
+// overlaps returns the entries of b that overlap x, sorted by Left.
+func (o *overlapper) overlaps(x BedEntry) []BedEntry {
+	o.advance(x)
+	var out []BedEntry
+	for _, y := range o.active {
+		if Intersect(x.Left, x.Right, y.Left, y.Right) {
+			out = append(out, y)
+		}
+	}
+	return out
+}
+
+// downstream returns the entry of b on x's chromosome with the smallest
+// Left at or after x.Right. It must follow a call to advance for x.
+func (o *overlapper) downstream(x BedEntry) (BedEntry, bool) {
+	var best BedEntry
+	found := false
+	for _, y := range o.active {
+		if y.Left >= x.Right && (!found || y.Left < best.Left) {
+			best, found = y, true
+		}
+	}
+	if !found && o.next != nil && o.next.Chrom == x.Chrom {
+		best, found = *o.next, true
+	}
+	return best, found
+}
+
+// drain reads the rest of b, so that its errors are reported and any
+// goroutines feeding it finish.
+func (o *overlapper) drain() {
+	for !o.done && o.b.Scan() {
+	}
+	o.done = true
+}
+
+// IntersectEntries reports the overlaps of the entries of a with those of
+// b, like bedtools intersect: each overlap keeps the chromosome, Val and
+// Other of its a entry and is clipped to the overlapping bases. Both inputs
+// must be sorted by Left within each chromosome and keep each chromosome's
+// entries together, but the chromosomes may come in different orders;
+// entries of b on chromosomes that a has not reached yet are buffered.
+func IntersectEntries(a, b BedOutputScanner) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		o := newOverlapper(b)
+		for a.Scan() {
+			x := a.Entry()
+			for _, y := range o.overlaps(x) {
+				v := x
+				v.Left = math.Max(x.Left, y.Left)
+				v.Right = math.Min(x.Right, y.Right)
+				out <- v
+			}
+		}
+		o.drain()
+		close(out)
+	}()
+	return out
+}
+
+// IntersectAll intersects the inputs in turn, so that the output covers
+// the bases present in every input. Each input must be sorted as for
+// IntersectEntries.
+func IntersectAll(ins ...BedOutputScanner) <-chan BedEntry {
+	if len(ins) == 0 {
+		out := make(chan BedEntry)
+		close(out)
+		return out
+	}
+	var acc BedOutputScanner = ins[0]
+	for _, in := range ins[1:] {
+		acc = NewBedEntryScanner(IntersectEntries(acc, in))
+	}
+	if s, ok := acc.(*BedEntryScanner); ok {
+		return s.Chan
+	}
+	out := make(chan BedEntry, 256)
+	go func() {
+		for acc.Scan() {
+			out <- acc.Entry()
+		}
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,81 @@
+package slide
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func collectEntries(in <-chan BedEntry) []BedEntry {
+	var out []BedEntry
+	for b := range in {
+		out = append(out, b)
+	}
+	return out
+}
+
+func TestIntersectEntries(t *testing.T) {
+	a := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 100, Val: 1},
+		{Chrom: "chr1", Left: 150, Right: 300, Val: 2},
+		{Chrom: "chr2", Left: 0, Right: 50, Val: 3},
+	}
+	b := []BedEntry{
+		{Chrom: "chr1", Left: 50, Right: 200},
+		{Chrom: "chr1", Left: 250, Right: 260},
+		{Chrom: "chr2", Left: 50, Right: 60},
+	}
+	got := collectEntries(IntersectEntries(NewBedSliceScanner(a), NewBedSliceScanner(b)))
+	want := []BedEntry{
+		{Chrom: "chr1", Left: 50, Right: 100, Val: 1},
+		{Chrom: "chr1", Left: 150, Right: 200, Val: 2},
+		{Chrom: "chr1", Left: 250, Right: 260, Val: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIntersectEntriesChromOrder(t *testing.T) {
+	a := []BedEntry{
+		{Chrom: "chr2", Left: 0, Right: 10},
+		{Chrom: "chr1", Left: 0, Right: 10},
+	}
+	b := []BedEntry{
+		{Chrom: "chr1", Left: 5, Right: 20},
+		{Chrom: "chr2", Left: 0, Right: 500},
+		{Chrom: "chr2", Left: 8, Right: 9},
+	}
+	got := collectEntries(IntersectEntries(NewBedSliceScanner(a), NewBedSliceScanner(b)))
+	want := []BedEntry{
+		{Chrom: "chr2", Left: 0, Right: 10},
+		{Chrom: "chr2", Left: 8, Right: 9},
+		{Chrom: "chr1", Left: 5, Right: 10},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIntersectAll(t *testing.T) {
+	beds := []string{
+		"chr1\t0\t100\nchr1\t200\t300\n",
+		"chr1\t50\t250\n",
+		"chr1\t0\t60\nchr1\t240\t400\n",
+	}
+	var ins []BedOutputScanner
+	for _, s := range beds {
+		ins = append(ins, NewBedReaderScanner(strings.NewReader(s)))
+	}
+	got := collectEntries(IntersectAll(ins...))
+	if len(got) != 2 {
+		t.Fatalf("got %v, want 2 entries", got)
+	}
+	if got[0].Left != 50 || got[0].Right != 60 || got[1].Left != 240 || got[1].Right != 250 {
+		t.Errorf("got %v", got)
+	}
+	if !math.IsNaN(got[0].Val) {
+		t.Errorf("3-column BED value = %v, want NaN", got[0].Val)
+	}
+}
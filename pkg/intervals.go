@@ -0,0 +1,304 @@
+package slide
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The interval operations below work on streams sorted by Left within each
+// chromosome, with each chromosome's entries together, as for
+// IntersectEntries.
+
+// ChromSizes holds chromosome lengths in file order, as read from a UCSC
+// .chrom.sizes file or the first two columns of a .fai index.
+type ChromSizes struct {
+	Names []string
+	Sizes map[string]float64
+}
+
+func ReadChromSizes(r io.Reader) (ChromSizes, error) {
+	h := handle("ReadChromSizes: %w")
+	out := ChromSizes{Sizes: map[string]float64{}}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || fields[0][0] == '#' {
+			continue
+		}
+		if len(fields) < 2 {
+			return out, h(fmt.Errorf("line %q has %v fields, want 2", s.Text(), len(fields)))
+		}
+		size, e := strconv.ParseFloat(fields[1], 64)
+		if e != nil { return out, h(e) }
+		if _, ok := out.Sizes[fields[0]]; !ok {
+			out.Names = append(out.Names, fields[0])
+		}
+		out.Sizes[fields[0]] = size
+	}
+	if e := s.Err(); e != nil {
+		return out, h(e)
+	}
+	return out, nil
+}
+
+// Merge joins entries that overlap or are at most maxGap bp apart. Each
+// merged entry has the number of entries it covers in Val.
+func Merge(in BedOutputScanner, maxGap float64) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		var cur BedEntry
+		started := false
+		for in.Scan() {
+			b := in.Entry()
+			if started && b.Chrom == cur.Chrom && b.Left - cur.Right <= maxGap {
+				cur.Right = math.Max(cur.Right, b.Right)
+				cur.Val++
+				continue
+			}
+			if started {
+				out <- cur
+			}
+			cur = BedEntry{Chrom: b.Chrom, Left: b.Left, Right: b.Right, Val: 1}
+			started = true
+		}
+		if started {
+			out <- cur
+		}
+		close(out)
+	}()
+	return out
+}
+
+// Subtract removes the bases covered by b from the entries of a. An entry
+// split by b is reported once per remaining piece; each piece keeps the Val
+// and Other of its entry.
+func Subtract(a, b BedOutputScanner) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		o := newOverlapper(b)
+		for a.Scan() {
+			x := a.Entry()
+			left := x.Left
+			for _, y := range o.overlaps(x) {
+				if y.Left > left {
+					v := x
+					v.Left, v.Right = left, y.Left
+					out <- v
+				}
+				left = math.Max(left, y.Right)
+			}
+			if left < x.Right {
+				v := x
+				v.Left = left
+				out <- v
+			}
+		}
+		o.drain()
+		close(out)
+	}()
+	return out
+}
+
+// Complement reports the bases of each chromosome in sizes not covered by
+// in, with NaN values. Chromosomes are reported in the order they appear in
+// in, followed by those of sizes that in does not mention. Chromosomes
+// missing from sizes only get the gaps between their entries.
+func Complement(in BedOutputScanner, sizes ChromSizes) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		seen := map[string]bool{}
+		chrom := ""
+		right := 0.0
+		started := false
+		finish := func() {
+			if size, ok := sizes.Sizes[chrom]; ok && right < size {
+				out <- BedEntry{Chrom: chrom, Left: right, Right: size, Val: math.NaN()}
+			}
+		}
+
+		for in.Scan() {
+			b := in.Entry()
+			if !started || b.Chrom != chrom {
+				if started {
+					finish()
+				}
+				started = true
+				chrom = b.Chrom
+				right = 0
+				seen[chrom] = true
+			}
+			if b.Left > right {
+				out <- BedEntry{Chrom: chrom, Left: right, Right: b.Left, Val: math.NaN()}
+			}
+			right = math.Max(right, b.Right)
+		}
+		if started {
+			finish()
+		}
+		for _, name := range sizes.Names {
+			if !seen[name] {
+				out <- BedEntry{Chrom: name, Left: 0, Right: sizes.Sizes[name], Val: math.NaN()}
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// PairFields describes the entry of b paired with an entry of a by Closest
+// or WindowAround. Distance is the number of bases between the two: 0 if
+// they overlap or touch, negative if the b entry is upstream of the a entry
+// and positive if it is downstream. Inner is the a entry's original Other,
+// whose columns are written first.
+type PairFields struct {
+	Start float64
+	End float64
+	Val float64
+	Distance float64
+	Inner interface{}
+}
+
+func (f PairFields) Columns() []string {
+	names, _ := ExtraColumns(BedEntry{Other: f.Inner})
+	return append(names, "b_start", "b_end", "b_value", "distance")
+}
+
+func (f PairFields) Values() []interface{} {
+	_, vals := ExtraColumns(BedEntry{Other: f.Inner})
+	return append(vals, f.Start, f.End, f.Val, f.Distance)
+}
+
+func pairDistance(x, y BedEntry) float64 {
+	switch {
+	case y.Right <= x.Left:
+		return y.Right - x.Left
+	case y.Left >= x.Right:
+		return y.Left - x.Right
+	}
+	return 0
+}
+
+func pair(x, y BedEntry) BedEntry {
+	x.Other = PairFields{Start: y.Left, End: y.Right, Val: y.Val, Distance: pairDistance(x, y), Inner: x.Other}
+	return x
+}
+
+// Closest pairs each entry of a with the nearest entry of b on the same
+// chromosome, reporting every overlapping entry, or both neighbours if the
+// upstream and downstream ones are equally near. Entries of a with no entry
+// of b on their chromosome are paired with NaN.
+func Closest(a, b BedOutputScanner) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		o := newOverlapper(b)
+		for a.Scan() {
+			x := a.Entry()
+			hits := o.overlaps(x)
+			for _, y := range hits {
+				out <- pair(x, y)
+			}
+			if len(hits) > 0 {
+				continue
+			}
+
+			down, hasDown := o.downstream(x)
+			up := o.upstream
+			switch {
+			case up == nil && !hasDown:
+				nan := math.NaN()
+				x.Other = PairFields{Start: nan, End: nan, Val: nan, Distance: nan, Inner: x.Other}
+				out <- x
+			case up == nil:
+				out <- pair(x, down)
+			case !hasDown:
+				out <- pair(x, *up)
+			default:
+				du, dd := -pairDistance(x, *up), pairDistance(x, down)
+				if du <= dd {
+					out <- pair(x, *up)
+				}
+				if dd <= du {
+					out <- pair(x, down)
+				}
+			}
+		}
+		o.drain()
+		close(out)
+	}()
+	return out
+}
+
+// WindowAround pairs each entry of a with every entry of b within left bp
+// upstream or right bp downstream of it, like bedtools window.
+func WindowAround(a, b BedOutputScanner, left, right float64) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		o := newOverlapper(b)
+		for a.Scan() {
+			x := a.Entry()
+			wide := x
+			wide.Left -= left
+			wide.Right += right
+			for _, y := range o.overlaps(wide) {
+				out <- pair(x, y)
+			}
+		}
+		o.drain()
+		close(out)
+	}()
+	return out
+}
+
+// JaccardStats compares the bases covered by two interval sets.
+type JaccardStats struct {
+	// Intersection and Union are in bp; overlapping entries within a set
+	// are merged first.
+	Intersection float64
+	Union float64
+	Jaccard float64
+	Intersections int
+}
+
+type coverageCounter struct {
+	BedOutputScanner
+	bp float64
+}
+
+func (c *coverageCounter) Scan() bool {
+	if !c.BedOutputScanner.Scan() {
+		return false
+	}
+	b := c.Entry()
+	c.bp += b.Right - b.Left
+	return true
+}
+
+// Jaccard reads a and b and reports the size of their intersection over
+// that of their union, like bedtools jaccard.
+func Jaccard(a, b BedOutputScanner) (JaccardStats, error) {
+	h := handle("Jaccard: %w")
+	ma := &coverageCounter{BedOutputScanner: NewBedEntryScanner(Merge(a, 0))}
+	mb := &coverageCounter{BedOutputScanner: NewBedEntryScanner(Merge(b, 0))}
+	var out JaccardStats
+	for v := range IntersectEntries(ma, mb) {
+		out.Intersection += v.Right - v.Left
+		out.Intersections++
+	}
+	out.Union = ma.bp + mb.bp - out.Intersection
+	out.Jaccard = ratio(out.Intersection, out.Union)
+	for _, s := range []BedOutputScanner{a, b} {
+		if e := scanErr(s); e != nil {
+			return JaccardStats{}, h(e)
+		}
+	}
+	return out, nil
+}
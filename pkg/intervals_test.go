@@ -0,0 +1,139 @@
+package slide
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func spans(bs []BedEntry) [][2]float64 {
+	var out [][2]float64
+	for _, b := range bs {
+		out = append(out, [2]float64{b.Left, b.Right})
+	}
+	return out
+}
+
+func TestMerge(t *testing.T) {
+	in := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 10},
+		{Chrom: "chr1", Left: 5, Right: 20},
+		{Chrom: "chr1", Left: 20, Right: 25},
+		{Chrom: "chr1", Left: 30, Right: 40},
+		{Chrom: "chr2", Left: 0, Right: 5},
+	}
+	got := collectEntries(Merge(NewBedSliceScanner(in), 0))
+	want := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 25, Val: 3},
+		{Chrom: "chr1", Left: 30, Right: 40, Val: 1},
+		{Chrom: "chr2", Left: 0, Right: 5, Val: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := collectEntries(Merge(NewBedSliceScanner(in), 5)); len(got) != 2 {
+		t.Errorf("maxGap 5: got %v, want 2 entries", got)
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	a := []BedEntry{{Chrom: "chr1", Left: 0, Right: 100, Val: 7}}
+	b := []BedEntry{
+		{Chrom: "chr1", Left: 10, Right: 20},
+		{Chrom: "chr1", Left: 15, Right: 30},
+		{Chrom: "chr1", Left: 90, Right: 120},
+	}
+	got := collectEntries(Subtract(NewBedSliceScanner(a), NewBedSliceScanner(b)))
+	want := [][2]float64{{0, 10}, {30, 90}}
+	if !reflect.DeepEqual(spans(got), want) {
+		t.Errorf("got %v, want %v", spans(got), want)
+	}
+	for _, v := range got {
+		if v.Val != 7 {
+			t.Errorf("Val = %v, want 7", v.Val)
+		}
+	}
+}
+
+func TestComplement(t *testing.T) {
+	sizes, e := ReadChromSizes(strings.NewReader("chr1\t100\nchr2\t50\n"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	in := []BedEntry{
+		{Chrom: "chr1", Left: 10, Right: 20},
+		{Chrom: "chr1", Left: 15, Right: 40},
+	}
+	got := collectEntries(Complement(NewBedSliceScanner(in), sizes))
+	want := [][2]float64{{0, 10}, {40, 100}, {0, 50}}
+	if !reflect.DeepEqual(spans(got), want) || got[2].Chrom != "chr2" {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func sameFloat(x, y float64) bool {
+	return x == y || (math.IsNaN(x) && math.IsNaN(y))
+}
+
+func TestClosest(t *testing.T) {
+	a := []BedEntry{
+		{Chrom: "chr1", Left: 100, Right: 110},
+		{Chrom: "chr1", Left: 150, Right: 160},
+		{Chrom: "chr2", Left: 0, Right: 10},
+	}
+	b := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 90},
+		{Chrom: "chr1", Left: 105, Right: 106},
+		{Chrom: "chr1", Left: 250, Right: 260},
+	}
+	got := collectEntries(Closest(NewBedSliceScanner(a), NewBedSliceScanner(b)))
+	if len(got) != 3 {
+		t.Fatalf("got %v, want 3 entries", got)
+	}
+	wantDist := []float64{0, -44, math.NaN()}
+	wantStart := []float64{105, 105, math.NaN()}
+	for i, v := range got {
+		f := v.Other.(PairFields)
+		if !sameFloat(f.Distance, wantDist[i]) || !sameFloat(f.Start, wantStart[i]) {
+			t.Errorf("entry %v: got %+v, want distance %v start %v", i, f, wantDist[i], wantStart[i])
+		}
+	}
+}
+
+func TestWindowAround(t *testing.T) {
+	a := []BedEntry{{Chrom: "chr1", Left: 100, Right: 110}}
+	b := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 50},
+		{Chrom: "chr1", Left: 80, Right: 95},
+		{Chrom: "chr1", Left: 115, Right: 120},
+		{Chrom: "chr1", Left: 130, Right: 140},
+	}
+	got := collectEntries(WindowAround(NewBedSliceScanner(a), NewBedSliceScanner(b), 10, 10))
+	var dists []float64
+	for _, v := range got {
+		dists = append(dists, v.Other.(PairFields).Distance)
+	}
+	if !reflect.DeepEqual(dists, []float64{-5, 5}) {
+		t.Errorf("got distances %v, want [-5 5]", dists)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	a := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 100},
+		{Chrom: "chr1", Left: 50, Right: 100},
+	}
+	b := []BedEntry{{Chrom: "chr1", Left: 50, Right: 150}}
+	got, err := Jaccard(NewBedSliceScanner(a), NewBedSliceScanner(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := JaccardStats{Intersection: 50, Union: 150, Jaccard: 50.0 / 150, Intersections: 1}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if _, err := Jaccard(NewBedSliceScanner(a), NewBedReaderScanner(strings.NewReader("chr1\tx\t2\n"))); err == nil {
+		t.Errorf("Jaccard with a bad input succeeded")
+	}
+}
@@ -0,0 +1,303 @@
+package slide
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+type MetageneOptions struct {
+	// Bins is the number of bins each feature body is scaled to.
+	Bins int
+	// Upstream and Downstream are the flank lengths in bp, each split into
+	// FlankBins bins. Flanks follow the feature's strand.
+	Upstream float64
+	Downstream float64
+	FlankBins int
+	// Agg reduces the track entries overlapping each bin.
+	Agg Aggregator
+}
+
+// DefaultMetageneOptions scales bodies to 100 bins with no flanks and
+// averages the track weighted by overlap.
+func DefaultMetageneOptions() MetageneOptions {
+	return MetageneOptions{Bins: 100, Agg: OverlapMeanAggregator}
+}
+
+func (o MetageneOptions) Validate() error {
+	h := handle("MetageneOptions.Validate: %w")
+	if o.Bins < 1 {
+		return h(fmt.Errorf("bins %v < 1", o.Bins))
+	}
+	if o.Upstream < 0 || o.Downstream < 0 {
+		return h(fmt.Errorf("negative flank length"))
+	}
+	if (o.Upstream > 0 || o.Downstream > 0) && o.FlankBins < 1 {
+		return h(fmt.Errorf("flanks need at least one flank bin"))
+	}
+	if o.Agg == nil {
+		return h(fmt.Errorf("no aggregator"))
+	}
+	return nil
+}
+
+func (o MetageneOptions) upBins() int {
+	if o.Upstream > 0 {
+		return o.FlankBins
+	}
+	return 0
+}
+
+func (o MetageneOptions) downBins() int {
+	if o.Downstream > 0 {
+		return o.FlankBins
+	}
+	return 0
+}
+
+// NBins returns the number of bins of each feature, flanks included.
+func (o MetageneOptions) NBins() int {
+	return o.upBins() + o.Bins + o.downBins()
+}
+
+// BinLabels names the bins from 5' to 3': up_1 and so on for the upstream
+// flank, body_1 and so on for the body, and down_1 and so on for the
+// downstream flank.
+func (o MetageneOptions) BinLabels() []string {
+	var out []string
+	for i := 0; i < o.upBins(); i++ {
+		out = append(out, fmt.Sprintf("up_%d", i + 1))
+	}
+	for i := 0; i < o.Bins; i++ {
+		out = append(out, fmt.Sprintf("body_%d", i + 1))
+	}
+	for i := 0; i < o.downBins(); i++ {
+		out = append(out, fmt.Sprintf("down_%d", i + 1))
+	}
+	return out
+}
+
+// EntryStrand returns the strand of a GFF feature or a BED interval with a
+// strand column, or '.' if it has none.
+func EntryStrand(b BedEntry) byte {
+	switch o := b.Other.(type) {
+	case GffFields:
+		if o.Strand == '+' || o.Strand == '-' {
+			return o.Strand
+		}
+	case BedColumns:
+		if len(o) > 2 && (o[2] == "+" || o[2] == "-") {
+			return o[2][0]
+		}
+	}
+	return '.'
+}
+
+// splitBins divides [left, right) into n equal bins.
+func splitBins(chrom string, left, right float64, n int) []BedEntry {
+	out := make([]BedEntry, n)
+	width := (right - left) / float64(n)
+	for i := range out {
+		out[i] = BedEntry{Chrom: chrom, Left: left + float64(i) * width, Right: left + float64(i + 1) * width}
+	}
+	out[n - 1].Right = right
+	return out
+}
+
+func reverseEntries(bs []BedEntry) {
+	for i, j := 0, len(bs) - 1; i < j; i, j = i + 1, j - 1 {
+		bs[i], bs[j] = bs[j], bs[i]
+	}
+}
+
+// MetageneBins returns the bins of feature f from 5' to 3'. Features on
+// the minus strand have their upstream flank on the right; features
+// without a strand are treated as plus strand.
+func MetageneBins(f BedEntry, opts MetageneOptions) []BedEntry {
+	minus := EntryStrand(f) == '-'
+	leftFlank, rightFlank := opts.Upstream, opts.Downstream
+	leftBins, rightBins := opts.upBins(), opts.downBins()
+	if minus {
+		leftFlank, rightFlank = rightFlank, leftFlank
+		leftBins, rightBins = rightBins, leftBins
+	}
+
+	var out []BedEntry
+	if leftBins > 0 {
+		out = append(out, splitBins(f.Chrom, f.Left - leftFlank, f.Left, leftBins)...)
+	}
+	out = append(out, splitBins(f.Chrom, f.Left, f.Right, opts.Bins)...)
+	if rightBins > 0 {
+		out = append(out, splitBins(f.Chrom, f.Right, f.Right + rightFlank, rightBins)...)
+	}
+	if minus {
+		reverseEntries(out)
+	}
+	return out
+}
+
+// MetageneFields holds the binned values of one feature from 5' to 3'.
+// Inner is the feature's original Other, whose columns are written first.
+type MetageneFields struct {
+	Labels []string
+	Bins []float64
+	Inner interface{}
+}
+
+func (f MetageneFields) Columns() []string {
+	names, _ := ExtraColumns(BedEntry{Other: f.Inner})
+	return append(names, f.Labels...)
+}
+
+func (f MetageneFields) Values() []interface{} {
+	_, vals := ExtraColumns(BedEntry{Other: f.Inner})
+	for _, v := range f.Bins {
+		vals = append(vals, v)
+	}
+	return vals
+}
+
+// MetageneMatrix bins each feature and its flanks, aggregating the track
+// entries overlapping each bin. Each feature keeps its coordinates, has the
+// mean of its body bins in Val and MetageneFields in Other. Both inputs must
+// be sorted as for IntersectEntries.
+func MetageneMatrix(features, track BedOutputScanner, opts MetageneOptions) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+	labels := opts.BinLabels()
+	flank := math.Max(opts.Upstream, opts.Downstream)
+
+	go func() {
+		o := newOverlapper(track)
+		for features.Scan() {
+			f := features.Entry()
+			// Widen both sides equally so queries stay sorted whatever the
+			// strand.
+			wide := f
+			wide.Left -= flank
+			wide.Right += flank
+			items := o.overlaps(wide)
+
+			fields := MetageneFields{Labels: labels, Inner: f.Other}
+			var body []float64
+			for i, bin := range MetageneBins(f, opts) {
+				var inBin []BedEntry
+				for _, b := range items {
+					if Intersect(bin.Left, bin.Right, b.Left, b.Right) {
+						inBin = append(inBin, b)
+					}
+				}
+				v := opts.Agg(bin, inBin)
+				fields.Bins = append(fields.Bins, v)
+				if i >= opts.upBins() && i < opts.upBins() + opts.Bins {
+					body = append(body, v)
+				}
+			}
+			f.Val = meanFinite(body)
+			f.Other = fields
+			out <- f
+		}
+		o.drain()
+		close(out)
+	}()
+	return out
+}
+
+func meanFinite(vals []float64) float64 {
+	sum, n := 0.0, 0.0
+	for _, v := range vals {
+		if !math.IsNaN(v) {
+			sum += v
+			n++
+		}
+	}
+	return ratio(sum, n)
+}
+
+// MetageneProfile averages binned feature values across features. NaN
+// bins are skipped.
+type MetageneProfile struct {
+	Opts MetageneOptions
+	Sum []float64
+	SumSq []float64
+	N []int
+	Features int
+}
+
+func NewMetageneProfile(opts MetageneOptions) *MetageneProfile {
+	n := opts.NBins()
+	return &MetageneProfile{Opts: opts, Sum: make([]float64, n), SumSq: make([]float64, n), N: make([]int, n)}
+}
+
+// Add adds the bins of an entry from MetageneMatrix.
+func (p *MetageneProfile) Add(b BedEntry) error {
+	f, ok := b.Other.(MetageneFields)
+	if !ok || len(f.Bins) != len(p.Sum) {
+		return fmt.Errorf("MetageneProfile.Add: entry %v has no matching MetageneFields", b)
+	}
+	p.Features++
+	for i, v := range f.Bins {
+		if math.IsNaN(v) {
+			continue
+		}
+		p.Sum[i] += v
+		p.SumSq[i] += v * v
+		p.N[i]++
+	}
+	return nil
+}
+
+func (p *MetageneProfile) Mean(i int) float64 {
+	return ratio(p.Sum[i], float64(p.N[i]))
+}
+
+// SD returns the sample standard deviation of bin i across features.
+func (p *MetageneProfile) SD(i int) float64 {
+	n := float64(p.N[i])
+	if n < 2 {
+		return math.NaN()
+	}
+	mean := p.Sum[i] / n
+	return math.Sqrt(math.Max(0, (p.SumSq[i] - n * mean * mean) / (n - 1)))
+}
+
+// Position returns the middle of bin i relative to the feature: bp before
+// the 5' end (negative) for upstream bins, the fraction of the body for
+// body bins, and bp after the 3' end for downstream bins.
+func (p *MetageneProfile) Position(i int) float64 {
+	o := p.Opts
+	up := o.upBins()
+	switch {
+	case i < up:
+		return -o.Upstream + (float64(i) + 0.5) * o.Upstream / float64(up)
+	case i < up + o.Bins:
+		return (float64(i - up) + 0.5) / float64(o.Bins)
+	}
+	return (float64(i - up - o.Bins) + 0.5) * o.Downstream / float64(o.downBins())
+}
+
+// WriteTsv writes one line per bin with its label, position, mean, standard
+// deviation and number of features with a value.
+func (p *MetageneProfile) WriteTsv(w io.Writer, opts WriterOptions) error {
+	h := handle("MetageneProfile.WriteTsv: %w")
+	bw := bufio.NewWriter(w)
+	if e := opts.writeComments(bw); e != nil {
+		return h(e)
+	}
+	if opts.Header {
+		if _, e := fmt.Fprintln(bw, strings.Join([]string{"#bin", "position", "mean", "sd", "n"}, "\t")); e != nil {
+			return h(e)
+		}
+	}
+	for i, label := range p.Opts.BinLabels() {
+		line := []string{label, opts.FormatFloat(p.Position(i)), opts.FormatFloat(p.Mean(i)), opts.FormatFloat(p.SD(i)), fmt.Sprint(p.N[i])}
+		if _, e := fmt.Fprintln(bw, strings.Join(line, "\t")); e != nil {
+			return h(e)
+		}
+	}
+	if e := bw.Flush(); e != nil {
+		return h(e)
+	}
+	return nil
+}
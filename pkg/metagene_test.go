@@ -0,0 +1,60 @@
+package slide
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMetageneMatrix(t *testing.T) {
+	// The track rises along chr1, so plus and minus strand features see it
+	// in opposite orders.
+	var track []BedEntry
+	for i := 0; i < 100; i++ {
+		track = append(track, BedEntry{Chrom: "chr1", Left: float64(i * 10), Right: float64(i * 10 + 10), Val: float64(i)})
+	}
+	features := []BedEntry{
+		{Chrom: "chr1", Left: 200, Right: 400, Other: BedColumns{"a", "0", "+"}},
+		{Chrom: "chr1", Left: 500, Right: 700, Other: BedColumns{"b", "0", "-"}},
+	}
+	opts := MetageneOptions{Bins: 4, Upstream: 100, Downstream: 50, FlankBins: 2, Agg: OverlapMeanAggregator}
+	if e := opts.Validate(); e != nil {
+		t.Fatal(e)
+	}
+
+	var rows []MetageneFields
+	p := NewMetageneProfile(opts)
+	for b := range MetageneMatrix(NewBedSliceScanner(features), NewBedSliceScanner(track), opts) {
+		rows = append(rows, b.Other.(MetageneFields))
+		if e := p.Add(b); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %v rows, want 2", len(rows))
+	}
+	// Plus strand: up 100-150, 150-200; body 200-250 ... 350-400; down
+	// 400-425, 425-450.
+	plus := []float64{12, 17, 22, 27, 32, 37, 40.8, 43.2}
+	// Minus strand: up 750-800, 700-750; body 650-700 ... 500-550; down
+	// 475-500, 450-475.
+	minus := []float64{77, 72, 67, 62, 57, 52, 48.2, 45.8}
+	for i := range plus {
+		if math.Abs(rows[0].Bins[i] - plus[i]) > 1e-9 || math.Abs(rows[1].Bins[i] - minus[i]) > 1e-9 {
+			t.Errorf("bin %v: got %v and %v, want %v and %v", i, rows[0].Bins[i], rows[1].Bins[i], plus[i], minus[i])
+		}
+	}
+	if got := p.Mean(0); got != (12 + 77) / 2.0 {
+		t.Errorf("profile mean of bin 0 = %v", got)
+	}
+
+	var buf bytes.Buffer
+	if e := p.WriteTsv(&buf, DefaultWriterOptions()); e != nil {
+		t.Fatal(e)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 8 || !strings.HasPrefix(lines[0], "up_1\t-75\t") || !strings.HasPrefix(lines[2], "body_1\t0.125\t") {
+		t.Errorf("profile output:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,338 @@
+package slide
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type MotifMatch struct {
+	Start int
+	End int
+	Score float64
+}
+
+// Motif finds matches on the forward strand of an upper case sequence.
+type Motif interface {
+	Name() string
+	Find(seq []byte) []MotifMatch
+}
+
+var iupacBases = map[byte]string{
+	'A': "A", 'C': "C", 'G': "G", 'T': "T", 'U': "T",
+	'R': "AG", 'Y': "CT", 'S': "CG", 'W': "AT", 'K': "GT", 'M': "AC",
+	'B': "CGT", 'D': "AGT", 'H': "ACT", 'V': "ACG", 'N': "ACGTN",
+}
+
+var complements = map[byte]byte{
+	'A': 'T', 'C': 'G', 'G': 'C', 'T': 'A', 'U': 'A',
+	'R': 'Y', 'Y': 'R', 'S': 'S', 'W': 'W', 'K': 'M', 'M': 'K',
+	'B': 'V', 'D': 'H', 'H': 'D', 'V': 'B', 'N': 'N',
+}
+
+func complement(c byte) byte {
+	lower := c >= 'a' && c <= 'z'
+	comp, ok := complements[c &^ 0x20]
+	if !ok {
+		return c
+	}
+	if lower {
+		return comp | 0x20
+	}
+	return comp
+}
+
+func RevComp(seq []byte) []byte {
+	out := make([]byte, len(seq))
+	for i, c := range seq {
+		out[len(seq) - 1 - i] = complement(c)
+	}
+	return out
+}
+
+type IupacMotif struct {
+	name string
+	Pattern string
+	sets []string
+}
+
+func NewIupacMotif(name string, pattern string) (*IupacMotif, error) {
+	m := &IupacMotif{name: name, Pattern: strings.ToUpper(pattern)}
+	for i := 0; i < len(m.Pattern); i++ {
+		set, ok := iupacBases[m.Pattern[i]]
+		if !ok {
+			return nil, fmt.Errorf("NewIupacMotif: invalid IUPAC code %q in %q", m.Pattern[i], pattern)
+		}
+		m.sets = append(m.sets, set)
+	}
+	if len(m.sets) == 0 {
+		return nil, fmt.Errorf("NewIupacMotif: empty pattern")
+	}
+	return m, nil
+}
+
+func (m *IupacMotif) Name() string {
+	return m.name
+}
+
+// Find reports every match, including overlapping ones.
+func (m *IupacMotif) Find(seq []byte) []MotifMatch {
+	var out []MotifMatch
+	for i := 0; i + len(m.sets) <= len(seq); i++ {
+		ok := true
+		for j, set := range m.sets {
+			if strings.IndexByte(set, seq[i+j]) < 0 {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			out = append(out, MotifMatch{Start: i, End: i + len(m.sets), Score: 1})
+		}
+	}
+	return out
+}
+
+type RegexMotif struct {
+	name string
+	Re *regexp.Regexp
+}
+
+// NewRegexMotif compiles expr case-insensitively.
+func NewRegexMotif(name string, expr string) (*RegexMotif, error) {
+	re, e := regexp.Compile("(?i)" + expr)
+	if e != nil {
+		return nil, fmt.Errorf("NewRegexMotif: %w", e)
+	}
+	return &RegexMotif{name: name, Re: re}, nil
+}
+
+func (m *RegexMotif) Name() string {
+	return m.name
+}
+
+// Find reports non-overlapping, non-empty matches.
+func (m *RegexMotif) Find(seq []byte) []MotifMatch {
+	var out []MotifMatch
+	for _, loc := range m.Re.FindAllIndex(seq, -1) {
+		if loc[1] > loc[0] {
+			out = append(out, MotifMatch{Start: loc[0], End: loc[1], Score: 1})
+		}
+	}
+	return out
+}
+
+// PwmMotif scores sequence with a log2 odds matrix against a uniform
+// background. Columns of Weights are in the order A, C, G, T.
+type PwmMotif struct {
+	name string
+	Weights [][4]float64
+	Threshold float64
+}
+
+// NewPwmMotif converts per-position base counts into log2 odds weights,
+// adding a pseudocount of one spread evenly across bases.
+func NewPwmMotif(name string, counts [][4]float64, threshold float64) *PwmMotif {
+	m := &PwmMotif{name: name, Threshold: threshold}
+	for _, c := range counts {
+		total := c[0] + c[1] + c[2] + c[3]
+		var w [4]float64
+		for i := range c {
+			w[i] = math.Log2(((c[i] + 0.25) / (total + 1)) / 0.25)
+		}
+		m.Weights = append(m.Weights, w)
+	}
+	return m
+}
+
+// ReadPwmCounts reads a count matrix as four rows, A, C, G and T, in plain
+// or JASPAR format. Header lines starting with '>' are skipped.
+func ReadPwmCounts(r io.Reader) ([][4]float64, error) {
+	h := handle("ReadPwmCounts: %w")
+	var rows [][]float64
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '>' || line[0] == '#' {
+			continue
+		}
+		line = strings.NewReplacer("[", " ", "]", " ").Replace(line)
+		var row []float64
+		for _, f := range strings.Fields(line) {
+			if _, ok := iupacBases[f[0]]; ok && len(f) == 1 {
+				continue
+			}
+			v, e := strconv.ParseFloat(f, 64)
+			if e != nil { return nil, h(e) }
+			row = append(row, v)
+		}
+		rows = append(rows, row)
+	}
+	if e := s.Err(); e != nil {
+		return nil, h(e)
+	}
+	if len(rows) != 4 {
+		return nil, h(fmt.Errorf("got %v rows, want 4", len(rows)))
+	}
+
+	out := make([][4]float64, len(rows[0]))
+	for i, row := range rows {
+		if len(row) != len(out) {
+			return nil, h(fmt.Errorf("row %v has %v columns, want %v", i, len(row), len(out)))
+		}
+		for j, v := range row {
+			out[j][i] = v
+		}
+	}
+	return out, nil
+}
+
+func (m *PwmMotif) Name() string {
+	return m.name
+}
+
+var pwmIndex = map[byte]int{'A': 0, 'C': 1, 'G': 2, 'T': 3}
+
+// Find reports every position scoring at least Threshold. Positions
+// covering bases other than ACGT are skipped.
+func (m *PwmMotif) Find(seq []byte) []MotifMatch {
+	var out []MotifMatch
+	for i := 0; i + len(m.Weights) <= len(seq); i++ {
+		score := 0.0
+		ok := true
+		for j, w := range m.Weights {
+			k, found := pwmIndex[seq[i+j]]
+			if !found {
+				ok = false
+				break
+			}
+			score += w[k]
+		}
+		if ok && score >= m.Threshold {
+			out = append(out, MotifMatch{Start: i, End: i + len(m.Weights), Score: score})
+		}
+	}
+	return out
+}
+
+type MotifHit struct {
+	Motif string
+	Strand byte
+}
+
+func (m MotifHit) Columns() []string {
+	return []string{"motif", "strand"}
+}
+
+func (m MotifHit) Values() []interface{} {
+	return []interface{}{m.Motif, string(m.Strand)}
+}
+
+// motifHits scans one sequence for every motif and returns the hits sorted
+// by position. A reverse strand match with the same span as a forward one,
+// as for palindromic motifs such as GATC, is reported once, on the forward
+// strand.
+func motifHits(fa FaEntry, motifs []Motif, bothStrands bool) []BedEntry {
+	seq := bytes.ToUpper(fa.Seq)
+	var rc []byte
+	if bothStrands {
+		rc = RevComp(seq)
+	}
+
+	var hits []BedEntry
+	add := func(start, end int, score float64, motif string, strand byte) {
+		hits = append(hits, BedEntry{
+			Chrom: fa.Name,
+			Left: float64(fa.Start + int64(start)),
+			Right: float64(fa.Start + int64(end)),
+			Val: score,
+			Other: MotifHit{Motif: motif, Strand: strand},
+		})
+	}
+
+	for _, m := range motifs {
+		forward := map[[2]int]bool{}
+		for _, match := range m.Find(seq) {
+			forward[[2]int{match.Start, match.End}] = true
+			add(match.Start, match.End, match.Score, m.Name(), '+')
+		}
+		if bothStrands {
+			for _, match := range m.Find(rc) {
+				start, end := len(seq) - match.End, len(seq) - match.Start
+				if forward[[2]int{start, end}] {
+					continue
+				}
+				add(start, end, match.Score, m.Name(), '-')
+			}
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Left != hits[j].Left {
+			return hits[i].Left < hits[j].Left
+		}
+		return hits[i].Right < hits[j].Right
+	})
+	return hits
+}
+
+// MotifHits scans each sequence for every motif, optionally on both
+// strands, and emits the hits sorted by position. Val holds the match
+// score, which is 1 for IUPAC and regex motifs, and Other a MotifHit.
+func MotifHits(in FaOutputScanner, motifs []Motif, bothStrands bool) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for in.Scan() {
+			for _, hit := range motifHits(in.Entry(), motifs, bothStrands) {
+				out <- hit
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// SlidingMotifCounts counts the motif hits starting in each window. Windows
+// follow SeqWindows, so every sequence is covered, including stretches
+// without hits.
+func SlidingMotifCounts(in FaOutputScanner, motifs []Motif, bothStrands bool, size float64, step float64) <-chan BedEntry {
+	mustWindow(size, step)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for in.Scan() {
+			fa := in.Entry()
+			hits := motifHits(fa, motifs, bothStrands)
+			SeqWindows(fa, size, step, func(left, right float64, seq []byte) {
+				first := sort.Search(len(hits), func(i int) bool { return hits[i].Left >= left })
+				last := sort.Search(len(hits), func(i int) bool { return hits[i].Left >= right })
+				out <- BedEntry{Chrom: fa.Name, Left: left, Right: right, Val: float64(last - first)}
+			})
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SlidingMotifCountsFull(inconn io.Reader, outconn io.Writer, motifs []Motif, bothStrands bool, size float64, step float64) error {
+	h := handle("SlidingMotifCountsFull: %w")
+	if e := CheckWindow(size, step); e != nil {
+		return h(e)
+	}
+	fa := NewFaScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingMotifCounts(fa, motifs, bothStrands, size, step))); e != nil {
+		return h(e)
+	}
+	if e := fa.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
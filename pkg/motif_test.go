@@ -0,0 +1,80 @@
+package slide
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMotifHits(t *testing.T) {
+	fa := ">chr1\nAAGATCAAAGGTCTT\n"
+	iupac, err := NewIupacMotif("gatc", "GATC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	re, err := NewRegexMotif("ggt", "ggt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hits []BedEntry
+	for b := range MotifHits(NewFaScanner(strings.NewReader(fa)), []Motif{iupac, re}, true) {
+		hits = append(hits, b)
+	}
+
+	expect := []struct {
+		Left float64
+		Motif string
+		Strand byte
+	}{
+		{2, "gatc", '+'},
+		{9, "ggt", '+'},
+	}
+	if len(hits) != len(expect) {
+		t.Fatalf("hits %v != expect %v", hits, expect)
+	}
+	for i, e := range expect {
+		h := hits[i].Other.(MotifHit)
+		if hits[i].Left != e.Left || h.Motif != e.Motif || h.Strand != e.Strand {
+			t.Errorf("hit %v: %v %+v != %+v", i, hits[i].Left, h, e)
+		}
+	}
+}
+
+func TestSlidingMotifCounts(t *testing.T) {
+	fa := ">chr1\nGATCAAAAAAAAAA\n>chr2\nAAAA\n"
+	iupac, err := NewIupacMotif("gatc", "GATC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []BedEntry
+	for b := range SlidingMotifCounts(NewFaScanner(strings.NewReader(fa)), []Motif{iupac}, true, 5, 5) {
+		got = append(got, b)
+	}
+	expect := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 5, Val: 1},
+		{Chrom: "chr1", Left: 5, Right: 10, Val: 0},
+		{Chrom: "chr1", Left: 10, Right: 15, Val: 0},
+		{Chrom: "chr2", Left: 0, Right: 5, Val: 0},
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("windows %v != expect %v", got, expect)
+	}
+	for i, e := range expect {
+		if got[i] != e {
+			t.Errorf("window %v: %v != %v", i, got[i], e)
+		}
+	}
+}
+
+func TestPwmMotif(t *testing.T) {
+	counts, err := ReadPwmCounts(strings.NewReader(">m\nA [ 10 0 ]\nC [ 0 0 ]\nG [ 0 10 ]\nT [ 0 0 ]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewPwmMotif("ag", counts, 3)
+	matches := m.Find([]byte("CAGTAGNAG"))
+	if len(matches) != 3 || matches[0].Start != 1 || matches[2].Start != 7 {
+		t.Errorf("matches %v", matches)
+	}
+}
@@ -0,0 +1,166 @@
+package slide
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jgbaldwinbrown/fasttsv"
+)
+
+var bedColumnNames = []string{"name", "score", "strand", "thick_start", "thick_end", "item_rgb", "block_count", "block_sizes", "block_starts"}
+
+// BedColumns holds the columns of a BED line after the coordinates, such as
+// the name, score and strand, so that they are written back out unchanged.
+type BedColumns []string
+
+func (c BedColumns) Columns() []string {
+	out := make([]string, len(c))
+	for i := range c {
+		if i < len(bedColumnNames) {
+			out[i] = bedColumnNames[i]
+		} else {
+			out[i] = fmt.Sprintf("col%d", i + 4)
+		}
+	}
+	return out
+}
+
+func (c BedColumns) Values() []interface{} {
+	out := make([]interface{}, len(c))
+	for i, v := range c {
+		out[i] = v
+	}
+	return out
+}
+
+// BedIntervalScanner reads BED intervals such as genes or peaks, which
+// need have no value column. Val is NaN and Other holds any columns after
+// the third as BedColumns. Blank, comment, track and browser lines are
+// skipped.
+type BedIntervalScanner struct {
+	Scanner *fasttsv.Scanner
+	CurEntry BedEntry
+	LastErr error
+}
+
+func NewBedIntervalScanner(r io.Reader) *BedIntervalScanner {
+	return &BedIntervalScanner{Scanner: fasttsv.NewScanner(r)}
+}
+
+func (s *BedIntervalScanner) Scan() bool {
+	h := handle("BedIntervalScanner: %w")
+	for s.Scanner.Scan() {
+		line := s.Scanner.Line()
+		if len(line) == 0 || line[0] == "" || strings.HasPrefix(line[0], "#") || strings.HasPrefix(line[0], "track") || strings.HasPrefix(line[0], "browser") {
+			continue
+		}
+		if len(line) < 3 {
+			s.LastErr = h(fmt.Errorf("line %q has %d columns, want at least 3", strings.Join(line, "\t"), len(line)))
+			return false
+		}
+		b := BedEntry{Chrom: line[0], Val: math.NaN()}
+		var e error
+		if b.Left, e = strconv.ParseFloat(line[1], 64); e != nil {
+			s.LastErr = h(e)
+			return false
+		}
+		if b.Right, e = strconv.ParseFloat(line[2], 64); e != nil {
+			s.LastErr = h(e)
+			return false
+		}
+		if len(line) > 3 {
+			b.Other = append(BedColumns(nil), line[3:]...)
+		}
+		s.CurEntry = b
+		return true
+	}
+	return false
+}
+
+func (s *BedIntervalScanner) Entry() BedEntry {
+	return s.CurEntry
+}
+
+func (s *BedIntervalScanner) Error() error {
+	return s.LastErr
+}
+
+// GffFeatures drops GFF comment entries and, if types is not empty, the
+// features whose type is not in types.
+func GffFeatures(in BedOutputScanner, types []string) *BedEntryScanner {
+	keep := map[string]bool{}
+	for _, t := range types {
+		keep[t] = true
+	}
+	return FilterEntries(in, func(b BedEntry) bool {
+		g, ok := b.Other.(GffFields)
+		if !ok || g.IsComment {
+			return false
+		}
+		return len(keep) == 0 || keep[g.Type]
+	})
+}
+
+// SortChecker passes entries through until one is out of order: before the
+// previous entry on its chromosome, or on a chromosome seen before another.
+// Scan then returns false and Error reports the entry.
+type SortChecker struct {
+	in BedOutputScanner
+	cur BedEntry
+	started bool
+	done map[string]bool
+	LastErr error
+}
+
+func NewSortChecker(in BedOutputScanner) *SortChecker {
+	return &SortChecker{in: in, done: map[string]bool{}}
+}
+
+func (s *SortChecker) Scan() bool {
+	if s.LastErr != nil || !s.in.Scan() {
+		return false
+	}
+	b := s.in.Entry()
+	if s.started && b.Chrom != s.cur.Chrom {
+		s.done[s.cur.Chrom] = true
+	}
+	if s.done[b.Chrom] || (s.started && b.Chrom == s.cur.Chrom && b.Left < s.cur.Left) {
+		s.LastErr = fmt.Errorf("SortChecker: %v:%v-%v is out of order after %v:%v-%v", b.Chrom, b.Left, b.Right, s.cur.Chrom, s.cur.Left, s.cur.Right)
+		return false
+	}
+	s.cur = b
+	s.started = true
+	return true
+}
+
+func (s *SortChecker) Entry() BedEntry {
+	return s.cur
+}
+
+func (s *SortChecker) Error() error {
+	return s.LastErr
+}
+
+// AggregateOver reports agg over the entries of track overlapping each
+// entry of windows, such as genes or peaks. Each window keeps its
+// coordinates and Other, with the aggregate in Val. Both inputs must be
+// sorted as for IntersectEntries; use an IntervalIndex for unsorted input.
+func AggregateOver(windows, track BedOutputScanner, agg Aggregator) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		o := newOverlapper(track)
+		for windows.Scan() {
+			win := windows.Entry()
+			items := o.overlaps(win)
+			win.Val = agg(win, items)
+			out <- win
+		}
+		o.drain()
+		close(out)
+	}()
+	return out
+}
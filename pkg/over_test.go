@@ -0,0 +1,78 @@
+package slide
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const overTrack = "chr1\t0\t10\t1\nchr1\t10\t20\t3\nchr1\t40\t50\t5\nchr2\t0\t100\t2\n"
+
+const overGenes = `track name=genes
+chr1	5	20	geneA	0	+
+chr1	30	60	geneB	0	-
+chr2	10	20	geneC	0	+
+chr3	0	10	geneD	0	+
+`
+
+func TestAggregateOver(t *testing.T) {
+	for _, c := range []struct {
+		agg Aggregator
+		want []float64
+	}{
+		{MeanAggregator, []float64{2, 5, 2, math.NaN()}},
+		{OverlapMeanAggregator, []float64{(5 * 1 + 10 * 3) / 15.0, 5, 2, math.NaN()}},
+		{CoveredAggregator, []float64{15, 10, 10, 0}},
+	} {
+		windows := NewBedIntervalScanner(strings.NewReader(overGenes))
+		track := NewBedReaderScanner(strings.NewReader(overTrack))
+		var got []float64
+		var names []string
+		for b := range AggregateOver(windows, track, c.agg) {
+			got = append(got, b.Val)
+			names = append(names, b.Other.(BedColumns)[0])
+		}
+		if windows.Error() != nil || track.Error() != nil {
+			t.Fatal(windows.Error(), track.Error())
+		}
+		if !reflect.DeepEqual(names, []string{"geneA", "geneB", "geneC", "geneD"}) {
+			t.Errorf("names %v", names)
+		}
+		for i := range c.want {
+			if !sameFloat(got[i], c.want[i]) {
+				t.Errorf("window %v: got %v, want %v", i, got[i], c.want[i])
+			}
+		}
+	}
+}
+
+func TestBedColumns(t *testing.T) {
+	s := NewBedIntervalScanner(strings.NewReader("chr1\t0\t10\tpeak1\t900\t.\tx\n"))
+	if !s.Scan() {
+		t.Fatal(s.Error())
+	}
+	names, vals := ExtraColumns(s.Entry())
+	if !reflect.DeepEqual(names, []string{"name", "score", "strand", "thick_start"}) {
+		t.Errorf("names %v", names)
+	}
+	if vals[0] != "peak1" {
+		t.Errorf("vals %v", vals)
+	}
+}
+
+func TestSortChecker(t *testing.T) {
+	in := []BedEntry{
+		{Chrom: "chr1", Left: 10},
+		{Chrom: "chr2", Left: 0},
+		{Chrom: "chr1", Left: 20},
+	}
+	s := NewSortChecker(NewBedSliceScanner(in))
+	n := 0
+	for s.Scan() {
+		n++
+	}
+	if n != 2 || s.Error() == nil {
+		t.Errorf("read %v entries with error %v, want 2 and an error", n, s.Error())
+	}
+}
@@ -0,0 +1,529 @@
+package slide
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Pipeline describes an input, a chain of stages and an output. It is read
+// from YAML or JSON (JSON being a subset of YAML), for example:
+//
+//	input:
+//	  path: scores.bedgraph
+//	  regions: [chr2L]
+//	stages:
+//	  - expr: abs(v)
+//	  - window: {stat: mean, size: 10kb, step: 50%}
+//	  - expr: -log10(v)
+//	  - threshold: 2
+//	  - span: {high: 3, max_gap: 10000}
+//	output:
+//	  path: windows.tsv
+//	  header: true
+//
+// Each stage sets exactly one of its fields.
+type Pipeline struct {
+	Input PipelineInput `yaml:"input"`
+	Stages []PipelineStage `yaml:"stages"`
+	Output PipelineOutput `yaml:"output"`
+}
+
+type PipelineInput struct {
+	// Path is the input file, or - for stdin.
+	Path string `yaml:"path"`
+	// Format is one of bed (the default), gff, wig, sync or vcf.
+	Format string `yaml:"format"`
+	// Pops is a VCF sample to population file.
+	Pops string `yaml:"pops,omitempty"`
+	Regions []string `yaml:"regions,omitempty"`
+}
+
+type PipelineOutput struct {
+	// Path is the output file, or - for stdout.
+	Path string `yaml:"path"`
+	Format string `yaml:"format"`
+	Precision *int `yaml:"precision"`
+	NA string `yaml:"na"`
+	Header bool `yaml:"header"`
+}
+
+type PipelineStage struct {
+	Expr string `yaml:"expr,omitempty"`
+	Abs bool `yaml:"abs,omitempty"`
+	// Log is the base of a logarithm stage.
+	Log float64 `yaml:"log,omitempty"`
+	Scale *ScaleStage `yaml:"scale,omitempty"`
+	// Clamp holds the lower and upper bounds of a clamp stage.
+	Clamp []float64 `yaml:"clamp,omitempty"`
+	Threshold *float64 `yaml:"threshold,omitempty"`
+	Rank bool `yaml:"rank,omitempty"`
+	Window *WindowStage `yaml:"window,omitempty"`
+	QValue *QValueStage `yaml:"qvalue,omitempty"`
+	Annotate *AnnotateStage `yaml:"annotate,omitempty"`
+	Span *SpanStage `yaml:"span,omitempty"`
+}
+
+type ScaleStage struct {
+	Factor float64 `yaml:"factor"`
+	Offset float64 `yaml:"offset"`
+}
+
+type QValueStage struct {
+	MaxInMemory int `yaml:"max_in_memory"`
+	TempDir string `yaml:"tmpdir,omitempty"`
+}
+
+// AnnotateStage adds the overlapping and nearest features of a GFF or BED
+// file, as for AnnotateOptions. Labels defaults to Name and ID.
+type AnnotateStage struct {
+	Features string `yaml:"features"`
+	Types []string `yaml:"types,omitempty"`
+	Labels []string `yaml:"labels,omitempty"`
+}
+
+// SpanStage merges hits into spans, as for SpanOptions. At least one of
+// High and Low must be set; the other defaults to no threshold. The input
+// must be sorted, as window output is.
+type SpanStage struct {
+	High *float64 `yaml:"high"`
+	Low *float64 `yaml:"low"`
+	MaxGap float64 `yaml:"max_gap"`
+	MinHits int `yaml:"min_hits,omitempty"`
+	MinLength float64 `yaml:"min_length,omitempty"`
+	LeftExtend float64 `yaml:"left_extend,omitempty"`
+	RightExtend float64 `yaml:"right_extend,omitempty"`
+}
+
+func (s *SpanStage) resolve() error {
+	if s.High == nil && s.Low == nil {
+		return fmt.Errorf("span needs high or low")
+	}
+	opts := DefaultSpanOptions()
+	if s.High == nil {
+		s.High = &opts.High
+	}
+	if s.Low == nil {
+		s.Low = &opts.Low
+	}
+	if s.MaxGap < 0 || s.MinLength < 0 || s.LeftExtend < 0 || s.RightExtend < 0 {
+		return fmt.Errorf("span lengths must not be negative")
+	}
+	return nil
+}
+
+func (s *SpanStage) options() SpanOptions {
+	return SpanOptions{
+		High: *s.High,
+		Low: *s.Low,
+		MaxGap: s.MaxGap,
+		MinHits: s.MinHits,
+		MinLength: s.MinLength,
+		LeftExtend: s.LeftExtend,
+		RightExtend: s.RightExtend,
+	}
+}
+
+// WindowStage slides windows over its input. Stat is an aggregator name
+// accepted by ParseAggregator, or one of gff-count, gff-bp, sync, fst,
+// diversity or cmh; the remaining fields are the options of those
+// statistics. Those need GFF or allele count input, so they cannot follow a
+// window, qvalue, annotate or span stage. Populations are 1-based, as on
+// the command line.
+type WindowStage struct {
+	Stat string `yaml:"stat"`
+	Size string `yaml:"size"`
+	Step string `yaml:"step"`
+
+	MinCount float64 `yaml:"min_count,omitempty"`
+	MinCoverage float64 `yaml:"min_coverage,omitempty"`
+	MaxCoverage float64 `yaml:"max_coverage,omitempty"`
+	Method string `yaml:"method,omitempty"`
+	Pairs [][2]int `yaml:"pairs,omitempty"`
+	PoolSizes []float64 `yaml:"pool_sizes,omitempty"`
+	Pop int `yaml:"pop,omitempty"`
+	PoolSize float64 `yaml:"pool_size,omitempty"`
+	Threshold float64 `yaml:"threshold,omitempty"`
+	WindowCallable bool `yaml:"window_callable,omitempty"`
+
+	size float64
+	step float64
+	agg Aggregator
+}
+
+var pipelineInputFormats = []string{"bed", "gff", "wig", "sync", "vcf"}
+
+// LoadPipeline reads a pipeline from YAML or JSON and resolves it.
+// Unknown fields are errors, so that misspelled parameters are not
+// silently ignored.
+func LoadPipeline(r io.Reader) (*Pipeline, error) {
+	h := handle("LoadPipeline: %w")
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var p Pipeline
+	if e := dec.Decode(&p); e != nil {
+		return nil, h(e)
+	}
+	if e := p.Resolve(); e != nil {
+		return nil, h(e)
+	}
+	return &p, nil
+}
+
+func LoadPipelineFile(path string) (*Pipeline, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, fmt.Errorf("LoadPipelineFile: %w", e)
+	}
+	defer f.Close()
+	return LoadPipeline(f)
+}
+
+// zeroBasedPairs converts 1-based population pairs to 0-based ones.
+func zeroBasedPairs(pairs [][2]int) [][2]int {
+	var out [][2]int
+	for _, p := range pairs {
+		out = append(out, [2]int{p[0] - 1, p[1] - 1})
+	}
+	return out
+}
+
+// Resolve validates the pipeline and fills in defaults, replacing window
+// sizes and steps with their values in bp so that the pipeline records the
+// parameters actually used.
+func (p *Pipeline) Resolve() error {
+	h := handle("Resolve: %w")
+	if p.Input.Path == "" {
+		p.Input.Path = "-"
+	}
+	if p.Input.Format == "" {
+		p.Input.Format = "bed"
+	}
+	p.Input.Format = strings.ToLower(p.Input.Format)
+	known := false
+	for _, f := range pipelineInputFormats {
+		known = known || f == p.Input.Format
+	}
+	if !known {
+		return h(fmt.Errorf("unknown input format %q; choose one of %v", p.Input.Format, strings.Join(pipelineInputFormats, ", ")))
+	}
+	for _, r := range p.Input.Regions {
+		if _, e := ParseRegion(r); e != nil {
+			return h(e)
+		}
+	}
+
+	if p.Output.Path == "" {
+		p.Output.Path = "-"
+	}
+	if p.Output.Format == "" {
+		p.Output.Format = "tsv"
+	}
+	if p.Output.Precision == nil {
+		prec := -1
+		p.Output.Precision = &prec
+	}
+	if p.Output.NA == "" {
+		p.Output.NA = "NaN"
+	}
+	if _, e := NewEntryWriter(p.Output.Format, io.Discard, p.writerOptions()); e != nil {
+		return h(e)
+	}
+
+	// Stages that replace the entries' fields leave only values for
+	// aggregator windows, whatever the input format.
+	format := p.Input.Format
+	for i := range p.Stages {
+		st := &p.Stages[i]
+		if e := st.resolve(format); e != nil {
+			return h(fmt.Errorf("stage %d: %w", i + 1, e))
+		}
+		if st.Window != nil || st.QValue != nil || st.Annotate != nil || st.Span != nil {
+			format = fmt.Sprintf("the output of stage %d", i + 1)
+		}
+	}
+	return nil
+}
+
+func (s *PipelineStage) resolve(inputFormat string) error {
+	n := 0
+	for _, set := range []bool{s.Expr != "", s.Abs, s.Log != 0, s.Scale != nil, s.Clamp != nil, s.Threshold != nil, s.Rank, s.Window != nil, s.QValue != nil, s.Annotate != nil, s.Span != nil} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("a stage must set exactly one of expr, abs, log, scale, clamp, threshold, rank, window, qvalue, annotate or span")
+	}
+	switch {
+	case s.Expr != "":
+		_, e := ParseExpr(s.Expr)
+		return e
+	case s.Log != 0 && !(s.Log > 0 && s.Log != 1):
+		return fmt.Errorf("log base %v is not positive and different from 1", s.Log)
+	case s.Clamp != nil && (len(s.Clamp) != 2 || s.Clamp[0] > s.Clamp[1]):
+		return fmt.Errorf("clamp needs [low, high]")
+	case s.Window != nil:
+		return s.Window.resolve(inputFormat)
+	case s.Annotate != nil:
+		if s.Annotate.Features == "" {
+			return fmt.Errorf("annotate needs features")
+		}
+		if s.Annotate.Labels == nil {
+			s.Annotate.Labels = DefaultAnnotateOptions().Labels
+		}
+	case s.Span != nil:
+		return s.Span.resolve()
+	}
+	return nil
+}
+
+func (w *WindowStage) resolve(inputFormat string) (err error) {
+	win := WindowFlags{Size: w.Size, Step: w.Step}
+	if w.size, w.step, err = win.Parse(); err != nil {
+		return err
+	}
+	w.Size = strconv.FormatFloat(w.size, 'f', -1, 64)
+	w.Step = strconv.FormatFloat(w.step, 'f', -1, 64)
+
+	needs := ""
+	switch w.Stat {
+	case "gff-count", "gff-bp":
+		if inputFormat != "gff" {
+			needs = "gff"
+		}
+	case "sync", "fst", "diversity", "cmh":
+		if inputFormat != "sync" && inputFormat != "vcf" {
+			needs = "sync or vcf"
+		}
+	default:
+		w.agg, err = ParseAggregator(w.Stat)
+		return err
+	}
+	if needs != "" {
+		return fmt.Errorf("window stat %s needs %s input, not %s", w.Stat, needs, inputFormat)
+	}
+
+	switch w.Stat {
+	case "sync":
+		if w.MinCount == 0 {
+			w.MinCount = 1
+		}
+	case "fst":
+		if w.Method == "" {
+			w.Method = FstHudson.String()
+		}
+		m, e := ParseFstMethod(w.Method)
+		if e != nil {
+			return e
+		}
+		if m == FstPool && w.PoolSizes == nil {
+			return fmt.Errorf("fst method pool needs pool_sizes")
+		}
+	case "diversity":
+		if w.Pop == 0 {
+			w.Pop = 1
+		}
+		if e := w.diversityOptions().Validate(); e != nil {
+			return e
+		}
+	case "cmh":
+		if len(w.Pairs) == 0 {
+			return fmt.Errorf("cmh needs pairs")
+		}
+		if w.Threshold == 0 {
+			w.Threshold = 0.05
+		}
+	}
+	for _, pair := range w.Pairs {
+		if pair[0] < 1 || pair[1] < 1 {
+			return fmt.Errorf("populations in pairs are 1-based")
+		}
+	}
+	if w.Pop < 0 {
+		return fmt.Errorf("pop is 1-based")
+	}
+	return nil
+}
+
+func (w *WindowStage) diversityOptions() DiversityOptions {
+	return DiversityOptions{
+		Pop: w.Pop - 1,
+		PoolSize: w.PoolSize,
+		MinCoverage: w.MinCoverage,
+		MaxCoverage: w.MaxCoverage,
+		MinCount: w.MinCount,
+		WindowCallable: w.WindowCallable,
+	}
+}
+
+func (w *WindowStage) apply(in BedOutputScanner) BedOutputScanner {
+	var out <-chan BedEntry
+	switch w.Stat {
+	case "gff-count":
+		out = SlidingGffEntryCount(in, w.size, w.step)
+	case "gff-bp":
+		out = SlidingGffBpCovered(in, w.size, w.step)
+	case "sync":
+		out = SlidingSyncStats(in, w.size, w.step, int64(w.MinCount))
+	case "fst":
+		m, _ := ParseFstMethod(w.Method)
+		out = SlidingFst(in, w.size, w.step, FstOptions{Method: m, Pairs: zeroBasedPairs(w.Pairs), PoolSizes: w.PoolSizes, MinCoverage: w.MinCoverage})
+	case "diversity":
+		out = SlidingDiversity(in, w.size, w.step, w.diversityOptions())
+	case "cmh":
+		out = SlidingCmh(in, w.size, w.step, CmhOptions{Pairs: zeroBasedPairs(w.Pairs), MinCoverage: w.MinCoverage, Threshold: w.Threshold})
+	default:
+		out = SlidingAggregate(in, w.size, w.step, w.agg)
+	}
+	return NewBedEntryScanner(out)
+}
+
+func (p *Pipeline) writerOptions() WriterOptions {
+	return WriterOptions{Precision: *p.Output.Precision, NA: p.Output.NA, Header: p.Output.Header}
+}
+
+// Comments returns the resolved pipeline as YAML lines, for recording in
+// the output header.
+func (p *Pipeline) Comments() []string {
+	out, e := yaml.Marshal(p)
+	if e != nil {
+		return nil
+	}
+	return append([]string{"slide pipeline:"}, strings.Split(strings.TrimRight(string(out), "\n"), "\n")...)
+}
+
+// PipelineRun is a built pipeline. Out yields the output entries; Error
+// reports input errors once Out is exhausted, and Close releases any
+// resources held by stages.
+type PipelineRun struct {
+	Out BedOutputScanner
+	src interface{}
+	closers []func() error
+}
+
+func (r *PipelineRun) Error() error {
+	return scanErr(r.src)
+}
+
+func (r *PipelineRun) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if e := c(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Build connects the stages of p to the input in. Stages that must read
+// all of their input, such as qvalue, do so here.
+func (p *Pipeline) Build(in io.Reader) (*PipelineRun, error) {
+	h := handle("Build: %w")
+	run := &PipelineRun{}
+	var s BedOutputScanner
+	switch p.Input.Format {
+	case "gff":
+		s = NewGffScanner(in)
+	case "wig":
+		s = NewWigScanner(in)
+	case "sync":
+		s = NewSyncReaderScanner(in)
+	case "vcf":
+		var pops map[string]int
+		if p.Input.Pops != "" {
+			f, e := os.Open(p.Input.Pops)
+			if e != nil {
+				return nil, h(e)
+			}
+			pops, _, e = ReadPopMap(f)
+			f.Close()
+			if e != nil {
+				return nil, h(e)
+			}
+		}
+		s = NewVcfScanner(in, pops)
+	default:
+		s = NewBedReaderScanner(in)
+	}
+	run.src = s
+
+	if len(p.Input.Regions) > 0 {
+		var regions []Region
+		for _, r := range p.Input.Regions {
+			reg, _ := ParseRegion(r)
+			regions = append(regions, reg)
+		}
+		s = NewBedEntryScanner(RegionFilter(s, regions))
+	}
+
+	for _, st := range p.Stages {
+		switch {
+		case st.Expr != "":
+			e, _ := ParseExpr(st.Expr)
+			s = e.Apply(s)
+		case st.Abs:
+			s = Abs(s)
+		case st.Log != 0:
+			s = Log(s, st.Log)
+		case st.Scale != nil:
+			s = Scale(s, st.Scale.Factor, st.Scale.Offset)
+		case st.Clamp != nil:
+			s = Clamp(s, st.Clamp[0], st.Clamp[1])
+		case st.Threshold != nil:
+			s = Threshold(s, *st.Threshold)
+		case st.Rank:
+			s = Rank(s)
+		case st.Window != nil:
+			s = st.Window.apply(s)
+		case st.QValue != nil:
+			q, e := CollectQValues(s, QValueOptions{MaxInMemory: st.QValue.MaxInMemory, TempDir: st.QValue.TempDir})
+			if e != nil {
+				run.Close()
+				return nil, h(e)
+			}
+			run.closers = append(run.closers, q.Close)
+			s = q
+		case st.Annotate != nil:
+			a, e := LoadAnnotator(st.Annotate.Features, AnnotateOptions{Types: st.Annotate.Types, Labels: st.Annotate.Labels})
+			if e != nil {
+				run.Close()
+				return nil, h(e)
+			}
+			s = a.Apply(s)
+		case st.Span != nil:
+			s = NewBedEntryScanner(CallSpans(s, st.Span.options()))
+		}
+	}
+	run.Out = s
+	return run, nil
+}
+
+// Run executes the pipeline from in to out, writing the resolved pipeline
+// as header comments.
+func (p *Pipeline) Run(in io.Reader, out io.Writer) error {
+	h := handle("Run: %w")
+	run, e := p.Build(in)
+	if e != nil {
+		return h(e)
+	}
+	defer run.Close()
+
+	opts := p.writerOptions()
+	opts.Comments = p.Comments()
+	w, e := NewEntryWriter(p.Output.Format, out, opts)
+	if e != nil {
+		return h(e)
+	}
+	if e := WriteEntries(w, run.Out); e != nil {
+		return h(e)
+	}
+	if e := run.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package slide
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testPipeline = `
+input:
+  regions: [chr1]
+stages:
+  - expr: abs(v)
+  - window: {stat: max, size: 2kb, step: 50%}
+  - threshold: 2
+output:
+  precision: 3
+`
+
+func TestPipelineRun(t *testing.T) {
+	p, err := LoadPipeline(strings.NewReader(testPipeline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := p.Stages[1].Window; w.Size != "2000" || w.Step != "1000" {
+		t.Errorf("resolved window %v/%v", w.Size, w.Step)
+	}
+
+	in := "chr1\t0\t10\t-3\nchr1\t1500\t1510\t1\nchr1\t2500\t2510\t1\nchr1\t2600\t2610\t1\nchr2\t0\t10\t9\n"
+	var out bytes.Buffer
+	if err := p.Run(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	var comments, data []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, line)
+		} else {
+			data = append(data, line)
+		}
+	}
+	if len(data) != 1 || data[0] != "chr1\t0\t2000\t3" {
+		t.Errorf("pipeline output %q", data)
+	}
+	if !strings.Contains(strings.Join(comments, "\n"), "size: \"2000\"") {
+		t.Errorf("resolved parameters missing from header:\n%s", strings.Join(comments, "\n"))
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"stages": [{"window": {"stat": "mean", "size": "1kb", "stpe": "1"}}]}`,
+		`{"stages": [{"abs": true, "rank": true}]}`,
+		`{"stages": [{"window": {"stat": "fst", "size": "1kb"}}]}`,
+		`{"input": {"format": "bam"}}`,
+		`{"stages": [{"expr": "v +"}]}`,
+		`{"stages": [{"annotate": {"types": ["gene"]}}]}`,
+		`{"input": {"format": "sync"}, "stages": [{"window": {"stat": "diversity", "size": "1kb", "pool_size": 1}}]}`,
+		`{"stages": [{"span": {"max_gap": 5}}]}`,
+		`{"input": {"format": "sync"}, "stages": [{"window": {"stat": "mean", "size": "10"}}, {"window": {"stat": "fst", "size": "10"}}]}`,
+		`{"input": {"format": "gff"}, "stages": [{"span": {"high": 1}}, {"window": {"stat": "gff-bp", "size": "10"}}]}`,
+	} {
+		if _, err := LoadPipeline(strings.NewReader(bad)); err == nil {
+			t.Errorf("LoadPipeline(%s) succeeded", bad)
+		}
+	}
+	if _, err := LoadPipeline(strings.NewReader(`{"input": {"format": "sync"}, "stages": [{"window": {"stat": "fst", "size": "1kb"}}]}`)); err != nil {
+		t.Errorf("JSON pipeline: %v", err)
+	}
+	_, err := LoadPipeline(strings.NewReader(`{"input": {"format": "sync"}, "stages": [{"expr": "v"}, {"window": {"stat": "mean", "size": "10"}}, {"window": {"stat": "fst", "size": "10"}}]}`))
+	if err == nil || !strings.Contains(err.Error(), "stage 3") || !strings.Contains(err.Error(), "output of stage 2") {
+		t.Errorf("fst after a window: got %v, want an error naming stages 3 and 2", err)
+	}
+}
+
+func TestPipelineAnnotate(t *testing.T) {
+	dir := t.TempDir()
+	features := filepath.Join(dir, "genes.gff")
+	if err := os.WriteFile(features, []byte(annotateGff), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPipeline(strings.NewReader(`{"stages": [{"annotate": {"features": "` + features + `", "types": ["gene"]}}], "output": {"header": true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := p.Run(strings.NewReader("chr1\t150\t160\t1\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "#chrom\tstart\tend\tvalue\tgene\tnearest\tnearest_distance\nchr1\t150\t160\t1\talpha\talpha\t0\n") {
+		t.Errorf("pipeline output:\n%s", out.String())
+	}
+}
+
+func TestPipelineSpan(t *testing.T) {
+	p, err := LoadPipeline(strings.NewReader(`{"stages": [{"window": {"stat": "mean", "size": "10"}}, {"span": {"high": 5}}], "output": {"format": "ndjson"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := "chr1\t0\t10\t6\nchr1\t10\t20\t7\nchr1\t20\t30\t1\nchr1\t30\t40\t9\nchr1\t40\t50\t1\n"
+	var out bytes.Buffer
+	if err := p.Run(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], `{"comments":["slide pipeline:"`) {
+		t.Fatalf("output %q", lines)
+	}
+	if !strings.HasPrefix(lines[1], `{"chrom":"chr1","start":0,"end":20,"value":7,`) || !strings.HasPrefix(lines[2], `{"chrom":"chr1","start":30,"end":40,`) {
+		t.Errorf("spans %q", lines[1:])
+	}
+
+	// The recorded parameters load back as the same pipeline.
+	again, err := LoadPipeline(strings.NewReader(strings.Join(p.Comments()[1:], "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *again.Stages[1].Span.Low != math.Inf(-1) || *again.Stages[1].Span.High != 5 {
+		t.Errorf("reloaded span stage %+v", again.Stages[1].Span)
+	}
+}
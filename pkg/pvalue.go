@@ -0,0 +1,57 @@
+package slide
+
+import (
+	"math"
+)
+
+// ChiSquareSurvival returns P(X > x) for a chi-square variable with df
+// degrees of freedom. Only df of 1 or an even number are supported, which
+// have closed forms; other values give NaN.
+func ChiSquareSurvival(x float64, df int) float64 {
+	if math.IsNaN(x) {
+		return math.NaN()
+	}
+	if x <= 0 {
+		return 1
+	}
+	if df == 1 {
+		return math.Erfc(math.Sqrt(x / 2))
+	}
+	if df < 2 || df % 2 != 0 {
+		return math.NaN()
+	}
+	// The survival function is the Poisson(x/2) probability of fewer than
+	// df/2 events. Its terms are summed in log space, since for large df
+	// exp(-x/2) underflows while the terms themselves overflow.
+	half := x / 2
+	logHalf := math.Log(half)
+	logTerms := make([]float64, df / 2)
+	top := math.Inf(-1)
+	for i := range logTerms {
+		lf, _ := math.Lgamma(float64(i + 1))
+		logTerms[i] = -half + float64(i) * logHalf - lf
+		top = math.Max(top, logTerms[i])
+	}
+	sum := 0.0
+	for _, t := range logTerms {
+		sum += math.Exp(t - top)
+	}
+	return math.Min(math.Exp(top + math.Log(sum)), 1)
+}
+
+// FisherCombined combines independent p-values with Fisher's method. NaN
+// p-values are skipped; with none left the result is NaN.
+func FisherCombined(ps []float64) float64 {
+	x, n := 0.0, 0
+	for _, p := range ps {
+		if math.IsNaN(p) {
+			continue
+		}
+		x += -2 * math.Log(math.Max(p, math.SmallestNonzeroFloat64))
+		n++
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return ChiSquareSurvival(x, 2 * n)
+}
@@ -0,0 +1,223 @@
+package slide
+
+import (
+	"encoding/gob"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// QValueFields holds the multiple-testing corrected values of an entry
+// whose Val is a p-value. Inner is the entry's original Other, whose
+// columns are written before the q-value columns.
+type QValueFields struct {
+	Q float64
+	Bonferroni float64
+	Inner interface{}
+}
+
+func (f QValueFields) Columns() []string {
+	names, _ := ExtraColumns(BedEntry{Other: f.Inner})
+	return append(names, "q_value", "bonferroni")
+}
+
+func (f QValueFields) Values() []interface{} {
+	_, vals := ExtraColumns(BedEntry{Other: f.Inner})
+	return append(vals, f.Q, f.Bonferroni)
+}
+
+// BenjaminiHochberg returns the Benjamini–Hochberg q-value of each p-value.
+// NaN p-values get NaN q-values and do not count as tests.
+func BenjaminiHochberg(ps []float64) []float64 {
+	var idx []int
+	for i, p := range ps {
+		if !math.IsNaN(p) {
+			idx = append(idx, i)
+		}
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return ps[idx[i]] < ps[idx[j]] })
+
+	out := make([]float64, len(ps))
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	m := float64(len(idx))
+	q := 1.0
+	for rank := len(idx); rank > 0; rank-- {
+		i := idx[rank - 1]
+		q = math.Min(q, ps[i] * m / float64(rank))
+		out[i] = q
+	}
+	return out
+}
+
+// Bonferroni returns min(1, p * m) for each p-value, where m is the number
+// of non-NaN p-values.
+func Bonferroni(ps []float64) []float64 {
+	m := 0.0
+	for _, p := range ps {
+		if !math.IsNaN(p) {
+			m++
+		}
+	}
+	out := make([]float64, len(ps))
+	for i, p := range ps {
+		out[i] = math.Min(p * m, 1)
+	}
+	return out
+}
+
+type QValueOptions struct {
+	// MaxInMemory is the number of entries kept in memory before the rest
+	// are spilled to a temporary file. Zero keeps everything in memory.
+	MaxInMemory int
+	// TempDir is where spill files are created; empty means os.TempDir().
+	TempDir string
+}
+
+// spilledEntry is the gob encoding of an entry. Other is flattened to its
+// extra columns, since arbitrary Other types cannot be decoded.
+type spilledEntry struct {
+	Chrom string
+	Left float64
+	Right float64
+	Val float64
+	Names []string
+	Vals []interface{}
+}
+
+type spilledColumns struct {
+	Names []string
+	Vals []interface{}
+}
+
+func (c spilledColumns) Columns() []string {
+	return c.Names
+}
+
+func (c spilledColumns) Values() []interface{} {
+	return c.Vals
+}
+
+// QValueScanner replays the entries collected by CollectQValues with
+// QValueFields in Other.
+type QValueScanner struct {
+	Q []float64
+	Bonferroni []float64
+	entries []BedEntry
+	file *os.File
+	dec *gob.Decoder
+	pos int
+	cur BedEntry
+	LastErr error
+}
+
+// CollectQValues reads all of in, treating Val as a p-value, and returns a
+// scanner that re-emits the entries with their q-values. Close must be
+// called to remove any spill file.
+func CollectQValues(in BedOutputScanner, opts QValueOptions) (*QValueScanner, error) {
+	h := handle("CollectQValues: %w")
+	s := &QValueScanner{pos: -1}
+	var ps []float64
+	var enc *gob.Encoder
+
+	for in.Scan() {
+		b := in.Entry()
+		ps = append(ps, b.Val)
+		if s.file == nil && (opts.MaxInMemory <= 0 || len(s.entries) < opts.MaxInMemory) {
+			s.entries = append(s.entries, b)
+			continue
+		}
+
+		if s.file == nil {
+			f, e := os.CreateTemp(opts.TempDir, "slide-qvalue-*.gob")
+			if e != nil {
+				return nil, h(e)
+			}
+			s.file = f
+			enc = gob.NewEncoder(f)
+		}
+		names, vals := ExtraColumns(b)
+		se := spilledEntry{Chrom: b.Chrom, Left: b.Left, Right: b.Right, Val: b.Val, Names: names, Vals: vals}
+		if e := enc.Encode(se); e != nil {
+			s.Close()
+			return nil, h(e)
+		}
+	}
+	if e := scanErr(in); e != nil {
+		s.Close()
+		return nil, h(e)
+	}
+
+	if s.file != nil {
+		if _, e := s.file.Seek(0, io.SeekStart); e != nil {
+			s.Close()
+			return nil, h(e)
+		}
+		s.dec = gob.NewDecoder(s.file)
+	}
+	s.Q = BenjaminiHochberg(ps)
+	s.Bonferroni = Bonferroni(ps)
+	return s, nil
+}
+
+func (s *QValueScanner) Scan() bool {
+	if s.pos + 1 >= len(s.Q) {
+		return false
+	}
+	s.pos++
+	if s.pos < len(s.entries) {
+		s.cur = s.entries[s.pos]
+	} else {
+		var se spilledEntry
+		if s.LastErr = s.dec.Decode(&se); s.LastErr != nil {
+			return false
+		}
+		s.cur = BedEntry{Chrom: se.Chrom, Left: se.Left, Right: se.Right, Val: se.Val}
+		if len(se.Names) > 0 {
+			s.cur.Other = spilledColumns{Names: se.Names, Vals: se.Vals}
+		}
+	}
+	s.cur.Other = QValueFields{Q: s.Q[s.pos], Bonferroni: s.Bonferroni[s.pos], Inner: s.cur.Other}
+	return true
+}
+
+func (s *QValueScanner) Entry() BedEntry {
+	return s.cur
+}
+
+func (s *QValueScanner) Error() error {
+	return s.LastErr
+}
+
+// Close removes the spill file, if any.
+func (s *QValueScanner) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	e := s.file.Close()
+	s.file = nil
+	if e2 := os.Remove(name); e == nil {
+		e = e2
+	}
+	return e
+}
+
+// QValuesFull reads bedGraph p-values, such as windowed output, and writes
+// them with q-value and Bonferroni columns.
+func QValuesFull(inconn io.Reader, outconn io.Writer, opts QValueOptions) error {
+	h := handle("QValuesFull: %w")
+	b := NewBedReaderScanner(inconn)
+	q, e := CollectQValues(b, opts)
+	if e != nil {
+		return h(e)
+	}
+	defer q.Close()
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, q); e != nil {
+		return h(e)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package slide
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestBenjaminiHochberg(t *testing.T) {
+	q := BenjaminiHochberg([]float64{0.04, 0.01, math.NaN(), 0.03, 0.5})
+	expect := []float64{0.04 * 4 / 3, 0.04, math.NaN(), 0.04 * 4 / 3, 0.5}
+	for i := range q {
+		if !(math.Abs(q[i] - expect[i]) < 1e-12 || math.IsNaN(q[i]) && math.IsNaN(expect[i])) {
+			t.Errorf("q %v != %v", q, expect)
+			break
+		}
+	}
+}
+
+func TestCollectQValuesSpill(t *testing.T) {
+	in := pvals(0.04, 0.01, 0.03, 0.5)
+	in[0].Other = 7.0
+	in[3].Other = CmhWindow{Sites: 2, MinP: 0.5, FisherP: 0.5}
+
+	write := func(opts QValueOptions) string {
+		q, err := CollectQValues(NewBedSliceScanner(in), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer q.Close()
+		var buf bytes.Buffer
+		if err := WriteEntries(NewTsvWriter(&buf, DefaultWriterOptions()), q); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	mem := write(QValueOptions{})
+	spilled := write(QValueOptions{MaxInMemory: 1, TempDir: t.TempDir()})
+	if mem != spilled {
+		t.Errorf("spilled output\n%s\ndiffers from in-memory output\n%s", spilled, mem)
+	}
+}
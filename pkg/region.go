@@ -0,0 +1,83 @@
+package slide
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Region is a 0-based, half-open genomic interval. A whole chromosome has
+// Start 0 and End +Inf.
+type Region struct {
+	Chrom string
+	Start float64
+	End float64
+}
+
+// ParseRegion parses "chrom" or "chrom:start-end" with 1-based inclusive
+// coordinates, as samtools does. Commas in the numbers are ignored.
+func ParseRegion(s string) (Region, error) {
+	h := handle("ParseRegion: %w")
+	colon := strings.LastIndex(s, ":")
+	if colon < 0 {
+		if s == "" {
+			return Region{}, h(fmt.Errorf("empty region"))
+		}
+		return Region{Chrom: s, End: math.Inf(1)}, nil
+	}
+	r := Region{Chrom: s[:colon]}
+	span := strings.ReplaceAll(s[colon + 1:], ",", "")
+	dash := strings.Index(span, "-")
+	if r.Chrom == "" || dash < 0 {
+		return Region{}, h(fmt.Errorf("region %q is not chrom or chrom:start-end", s))
+	}
+	start, e := strconv.ParseFloat(span[:dash], 64)
+	if e != nil {
+		return Region{}, h(e)
+	}
+	end, e := strconv.ParseFloat(span[dash + 1:], 64)
+	if e != nil {
+		return Region{}, h(e)
+	}
+	if start < 1 || end < start {
+		return Region{}, h(fmt.Errorf("region %q has invalid coordinates", s))
+	}
+	r.Start, r.End = start - 1, end
+	return r, nil
+}
+
+func (r Region) String() string {
+	if math.IsInf(r.End, 1) {
+		return r.Chrom
+	}
+	return fmt.Sprintf("%s:%d-%d", r.Chrom, int64(r.Start) + 1, int64(r.End))
+}
+
+func (r Region) Overlaps(b BedEntry) bool {
+	return b.Chrom == r.Chrom && b.Left < r.End && b.Right > r.Start
+}
+
+// RegionFilter passes on the entries of in that overlap any of regions. With
+// no regions every entry is passed on.
+func RegionFilter(in BedOutputScanner, regions []Region) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for in.Scan() {
+			b := in.Entry()
+			keep := len(regions) == 0
+			for _, r := range regions {
+				if r.Overlaps(b) {
+					keep = true
+					break
+				}
+			}
+			if keep {
+				out <- b
+			}
+		}
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,35 @@
+package slide
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseRegion(t *testing.T) {
+	r, err := ParseRegion("chr2L:1,001-2,000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != (Region{Chrom: "chr2L", Start: 1000, End: 2000}) || r.String() != "chr2L:1001-2000" {
+		t.Errorf("region %+v", r)
+	}
+	if r, err = ParseRegion("chrX"); err != nil || !math.IsInf(r.End, 1) {
+		t.Errorf("whole chromosome region %+v, %v", r, err)
+	}
+	for _, bad := range []string{"", "chr1:5", "chr1:10-5", ":1-2"} {
+		if _, err := ParseRegion(bad); err == nil {
+			t.Errorf("ParseRegion(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRegionFilter(t *testing.T) {
+	in := []BedEntry{{Chrom: "chr1", Left: 0, Right: 10}, {Chrom: "chr1", Left: 10, Right: 20}, {Chrom: "chr2", Left: 0, Right: 10}}
+	var out []BedEntry
+	for b := range RegionFilter(NewBedSliceScanner(in), []Region{{Chrom: "chr1", Start: 10, End: 15}}) {
+		out = append(out, b)
+	}
+	if len(out) != 1 || out[0].Left != 10 {
+		t.Errorf("filtered %v", out)
+	}
+}
@@ -0,0 +1,158 @@
+package slide
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type sizeUnit struct {
+	suffix string
+	scale float64
+}
+
+var sizeUnits = []sizeUnit{
+	{"gb", 1e9}, {"mb", 1e6}, {"kb", 1e3}, {"bp", 1},
+	{"g", 1e9}, {"m", 1e6}, {"k", 1e3}, {"b", 1},
+}
+
+var geneticUnits = []sizeUnit{{"cm", 1}}
+
+// ParseSize parses a positive length in bp with an optional unit suffix,
+// such as 500, 10kb or 1.5Mb. Units are case-insensitive.
+func ParseSize(s string) (float64, error) {
+	return parseSize(s, sizeUnits)
+}
+
+// ParseGeneticSize parses a positive genetic length in cM, such as 0.5 or
+// 0.5cM.
+func ParseGeneticSize(s string) (float64, error) {
+	return parseSize(s, geneticUnits)
+}
+
+func parseSize(s string, units []sizeUnit) (float64, error) {
+	h := handle("ParseSize: %w")
+	num, scale := strings.TrimSpace(s), 1.0
+	lower := strings.ToLower(num)
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			num, scale = num[:len(num) - len(u.suffix)], u.scale
+			break
+		}
+	}
+	v, e := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if e != nil {
+		return 0, h(fmt.Errorf("invalid size %q", s))
+	}
+	v *= scale
+	if !(v > 0) || math.IsInf(v, 0) {
+		return 0, h(fmt.Errorf("size %q must be positive", s))
+	}
+	return v, nil
+}
+
+// ParseStep parses a window step. Besides the forms accepted by ParseSize,
+// a step may be a fraction of size written as a percentage (50%) or a
+// multiple (0.5x). An empty step equals size.
+func ParseStep(s string, size float64) (float64, error) {
+	return parseStep(s, size, sizeUnits)
+}
+
+// ParseGeneticStep is ParseStep for steps in cM.
+func ParseGeneticStep(s string, size float64) (float64, error) {
+	return parseStep(s, size, geneticUnits)
+}
+
+func parseStep(s string, size float64, units []sizeUnit) (float64, error) {
+	h := handle("ParseStep: %w")
+	t := strings.TrimSpace(s)
+	if t == "" {
+		return size, nil
+	}
+	frac := 0.0
+	switch {
+	case strings.HasSuffix(t, "%"):
+		frac = 0.01
+	case strings.HasSuffix(t, "x") || strings.HasSuffix(t, "X"):
+		frac = 1
+	default:
+		v, e := parseSize(t, units)
+		if e != nil {
+			return 0, h(e)
+		}
+		return v, nil
+	}
+	v, e := strconv.ParseFloat(strings.TrimSpace(t[:len(t) - 1]), 64)
+	if e != nil {
+		return 0, h(fmt.Errorf("invalid step %q", s))
+	}
+	v *= frac * size
+	if !(v > 0) || math.IsInf(v, 0) {
+		return 0, h(fmt.Errorf("step %q must be positive", s))
+	}
+	return v, nil
+}
+
+// CheckWindow returns an error unless size and step are positive, since a
+// Slider cannot advance otherwise. The Sliding functions that return an
+// error check it themselves; those that return a channel panic before
+// starting any goroutine, so callers that take size and step from users
+// should check first.
+func CheckWindow(size float64, step float64) error {
+	if !(size > 0) || !(step > 0) {
+		return fmt.Errorf("window size %v and step %v must be positive", size, step)
+	}
+	return nil
+}
+
+func mustWindow(size float64, step float64) {
+	if e := CheckWindow(size, step); e != nil {
+		panic(e)
+	}
+}
+
+// WindowFlags registers the -size and -step flags shared by the commands.
+// Values set before Register are used as the flag defaults.
+type WindowFlags struct {
+	Size string
+	Step string
+	// Genetic makes Parse read the size and step in cM.
+	Genetic bool
+}
+
+func (f *WindowFlags) Register(fs *flag.FlagSet) {
+	fs.StringVar(&f.Size, "size", f.Size, "Window size in bp, with an optional unit such as 10kb or 1.5Mb")
+	fs.StringVar(&f.Step, "step", f.Step, "Window step in bp, or a fraction of -size such as 50% or 0.5x (default: the window size)")
+}
+
+// RegisterLegacy adds older names for -size and -step, such as -w and -s,
+// for commands that used them. Empty names are not registered.
+func (f *WindowFlags) RegisterLegacy(fs *flag.FlagSet, size string, step string) {
+	if size != "" {
+		fs.StringVar(&f.Size, size, f.Size, "Deprecated name for -size")
+	}
+	if step != "" {
+		fs.StringVar(&f.Step, step, f.Step, "Deprecated name for -step")
+	}
+}
+
+// Parse returns the window size and step in bp, or in cM if Genetic is
+// set.
+func (f *WindowFlags) Parse() (size float64, step float64, err error) {
+	if f.Size == "" {
+		return 0, 0, fmt.Errorf("a window -size is required")
+	}
+	units := sizeUnits
+	if f.Genetic {
+		units = geneticUnits
+	}
+	if size, err = parseSize(f.Size, units); err != nil {
+		return 0, 0, err
+	}
+	if step, err = parseStep(f.Step, size, units); err != nil {
+		return 0, 0, err
+	}
+	return size, step, nil
+}
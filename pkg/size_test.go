@@ -0,0 +1,69 @@
+package slide
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]float64{"500": 500, "10kb": 10000, "1.5Mb": 1.5e6, "2K": 2000, "100bp": 100, "1g": 1e9} {
+		if got, err := ParseSize(in); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0", "-5kb", "ten", "5 parsecs"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) succeeded", in)
+		}
+	}
+}
+
+func TestParseStep(t *testing.T) {
+	for in, want := range map[string]float64{"": 10000, "50%": 5000, "0.25x": 2500, "1kb": 1000} {
+		if got, err := ParseStep(in, 10000); err != nil || got != want {
+			t.Errorf("ParseStep(%q, 10000) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"0%", "0x", "0", "-1x"} {
+		if _, err := ParseStep(in, 10000); err == nil {
+			t.Errorf("ParseStep(%q) succeeded", in)
+		}
+	}
+}
+
+func TestParseGeneticSize(t *testing.T) {
+	for in, want := range map[string]float64{"0.5": 0.5, "2cM": 2, "1.5cm": 1.5} {
+		if got, err := ParseGeneticSize(in); err != nil || got != want {
+			t.Errorf("ParseGeneticSize(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseGeneticSize("10kb"); err == nil {
+		t.Errorf("ParseGeneticSize(\"10kb\") succeeded")
+	}
+	f := WindowFlags{Size: "1cM", Step: "50%", Genetic: true}
+	if size, step, err := f.Parse(); err != nil || size != 1 || step != 0.5 {
+		t.Errorf("genetic WindowFlags.Parse() = %v, %v, %v", size, step, err)
+	}
+}
+
+func TestNewSliderZeroStep(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NewSlider with a zero step did not panic")
+		}
+	}()
+	NewSlider(NewBedSliceScanner(nil), 10, 0)
+}
+
+func TestBadWindowErrors(t *testing.T) {
+	var out strings.Builder
+	if err := SlidingSeqStatsFull(strings.NewReader(">chr1\nACGT\n"), &out, 10, 0); err == nil {
+		t.Errorf("SlidingSeqStatsFull with a zero step succeeded")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SlidingSeqStats with a zero step did not panic")
+		}
+	}()
+	SlidingSeqStats(NewFaScanner(strings.NewReader(">chr1\nACGT\n")), 10, 0)
+}
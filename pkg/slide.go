@@ -1,9 +1,10 @@
 package slide
 
 import (
+	"fmt"
 	"math"
 	"strconv"
-	"fmt"
+	"strings"
 	"github.com/jgbaldwinbrown/fasttsv"
 	"github.com/montanaflynn/stats"
 	"container/list"
@@ -57,6 +58,10 @@ func (s *BedScanner) Scan() bool {
 		return ok
 	}
 	line := s.Scanner.Line()
+	if len(line) < 3 {
+		s.LastErr = fmt.Errorf("BedScanner: line %q has %d columns, want at least 3", strings.Join(line, "\t"), len(line))
+		return false
+	}
 
 	s.CurEntry.Chrom = line[0]
 
@@ -69,7 +74,8 @@ func (s *BedScanner) Scan() bool {
 		return false
 	}
 
-	if line[3] == "-nan" {
+	// Plain BED intervals have no value column.
+	if len(line) < 4 || line[3] == "-nan" {
 		s.CurEntry.Val = math.NaN()
 	} else {
 		s.CurEntry.Val, s.LastErr = strconv.ParseFloat(line[3], 64)
@@ -85,6 +91,10 @@ func (s *BedScanner) Entry() BedEntry {
 	return s.CurEntry
 }
 
+func (s *BedScanner) Error() error {
+	return s.LastErr
+}
+
 type BedEntryScanner struct {
 	Chan <-chan BedEntry
 	Current BedEntry
@@ -104,6 +114,37 @@ func (s *BedEntryScanner) Entry() BedEntry {
 	return s.Current
 }
 
+type BedSliceScanner struct {
+	Entries []BedEntry
+	Pos int
+}
+
+func NewBedSliceScanner(entries []BedEntry) *BedSliceScanner {
+	return &BedSliceScanner{Entries: entries, Pos: -1}
+}
+
+func (s *BedSliceScanner) Scan() bool {
+	if s.Pos >= len(s.Entries) - 1 {
+		s.Pos = len(s.Entries)
+		return false
+	}
+	s.Pos++
+	return true
+}
+
+func (s *BedSliceScanner) Entry() BedEntry {
+	return s.Entries[s.Pos]
+}
+
+// scanErr returns the error recorded by scanners that keep one, such as
+// GffScanner.
+func scanErr(s interface{}) error {
+	if es, ok := s.(interface{ Error() error }); ok {
+		return es.Error()
+	}
+	return nil
+}
+
 type SyncScanner struct {
 	Scanner *fasttsv.Scanner
 	CurEntry BedEntry
@@ -112,16 +153,24 @@ type SyncScanner struct {
 
 func NewSyncScanner(s *fasttsv.Scanner) *SyncScanner {
 	b := &SyncScanner{Scanner: s}
-	b.Scan()
+	// b.Scan()
 	return b
 }
 
+func NewSyncReaderScanner(r io.Reader) *SyncScanner {
+	return NewSyncScanner(fasttsv.NewScanner(r))
+}
+
 func (s *SyncScanner) Scan() bool {
 	ok := s.Scanner.Scan()
 	if !ok {
 		return ok
 	}
 	line := s.Scanner.Line()
+	if len(line) < 3 {
+		s.LastErr = fmt.Errorf("SyncScanner.Scan: len(line) %v < 3", len(line))
+		return false
+	}
 	s.CurEntry.Chrom = line[0]
 	s.CurEntry.Left, s.LastErr = strconv.ParseFloat(line[1], 64)
 	if s.LastErr != nil {
@@ -134,9 +183,12 @@ func (s *SyncScanner) Scan() bool {
 	}
 	s.CurEntry.Val = 1
 
+	var f SyncFields
+	f, s.LastErr = ParseSyncFields(line[2:])
 	if s.LastErr != nil {
 		return false
 	}
+	s.CurEntry.Other = f
 	return true
 }
 
@@ -144,6 +196,10 @@ func (s *SyncScanner) Entry() BedEntry {
 	return s.CurEntry
 }
 
+func (s *SyncScanner) Error() error {
+	return s.LastErr
+}
+
 type BedOutputScanner interface {
 	Scan() bool
 	Entry() BedEntry
@@ -164,7 +220,9 @@ type Slider struct {
 	DoneOutputting bool
 }
 
+// NewSlider panics if CheckWindow(size, step) fails.
 func NewSlider(b BedOutputScanner, size float64, step float64) Slider {
+	mustWindow(size, step)
 	s := Slider{Size: size, StepLen: step, Scanner: b, Items: list.New(), DoneReading: false, DoneOutputting: false, StartingChrom: true, Unused: list.New()}
 	return s
 }
@@ -364,6 +422,14 @@ func  Ahead(query_left, query_right, subject_left, subject_right float64) bool {
 // 	return false
 // }
 
+func (s *Slider) Entries() []BedEntry {
+	out := make([]BedEntry, 0, s.Items.Len())
+	for elem := s.Items.Front(); elem != nil; elem = elem.Next() {
+		out = append(out, elem.Value.(BedEntry))
+	}
+	return out
+}
+
 func (s *Slider) Mean() (float64, error) {
 	var vals []float64
 	for elem := s.Items.Back(); elem != nil; elem = elem.Prev() {
@@ -403,33 +469,56 @@ func (s *Slider) MeanEntry() (BedEntry, error) {
 	return out, nil
 }
 
+func (s *Slider) SumEntry() (BedEntry, error) {
+	out := BedEntry {
+		Left: s.Left,
+		Right: s.Right,
+		Chrom: s.Chrom,
+	}
+	sum, err := s.Sum()
+	if err == stats.EmptyInputErr {
+		sum, err = 0, nil
+	}
+	if err != nil {
+		return BedEntry{}, err
+	}
+	out.Val = sum
+	return out, nil
+}
+
+func (s *Slider) CountEntry() BedEntry {
+	return BedEntry {
+		Left: s.Left,
+		Right: s.Right,
+		Chrom: s.Chrom,
+		Val: float64(s.Items.Len()),
+	}
+}
+
 func (s *Slider) WriteWindow(w LineWriter) {
-	mean, err := s.Mean()
-	if err == nil {
-		w.Write([]string{s.Chrom, fmt.Sprintf("%d", int(s.Left)), fmt.Sprintf("%d", int(s.Right)), fmt.Sprintf("%g", mean)})
-	} else {
-		w.Write([]string{s.Chrom, fmt.Sprintf("%d", int(s.Left)), fmt.Sprintf("%d", int(s.Right)), "NA"})
+	opts := DefaultWriterOptions()
+	entry, err := s.MeanEntry()
+	if err != nil {
+		entry = BedEntry{Chrom: s.Chrom, Left: s.Left, Right: s.Right, Val: math.NaN()}
+		opts.NA = "NA"
 	}
+	w.Write(opts.Fields(entry))
 }
 
 func (s *Slider) WriteWindowSum(w LineWriter) {
-	sum, err := s.Sum()
-	if err == nil {
-		w.Write([]string{s.Chrom, fmt.Sprintf("%d", int(s.Left)), fmt.Sprintf("%d", int(s.Right)), fmt.Sprintf("%g", sum)})
-	} else {
-		w.Write([]string{s.Chrom, fmt.Sprintf("%d", int(s.Left)), fmt.Sprintf("%d", int(s.Right)), "NA"})
+	opts := DefaultWriterOptions()
+	entry, err := s.SumEntry()
+	if err != nil {
+		entry = BedEntry{Chrom: s.Chrom, Left: s.Left, Right: s.Right, Val: math.NaN()}
+		opts.NA = "NA"
 	}
+	w.Write(opts.Fields(entry))
 }
 
 func SlidingMeans(inconn io.Reader, outconn io.Writer, size float64, step float64) {
 	b := NewBedScanner(fasttsv.NewScanner(inconn))
-	s := NewSlider(b, size, step)
-	w := fasttsv.NewWriter(outconn)
-	defer w.Flush()
-
-	for s.Step() {
-		s.WriteWindow(w)
-	}
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	WriteEntries(w, NewBedEntryScanner(SlidingEntryMeans(b, size, step)))
 }
 
 func SlidingEntryMeans(in BedOutputScanner, size float64, step float64) <-chan BedEntry {
@@ -449,13 +538,39 @@ func SlidingEntryMeans(in BedOutputScanner, size float64, step float64) <-chan B
 	return out
 }
 
+func SlidingEntrySums(in BedOutputScanner, size float64, step float64) <-chan BedEntry {
+	s := NewSlider(in, size, step)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for s.Step() {
+			entry, e := s.SumEntry()
+			if e != nil {
+				panic(e)
+			}
+			out <- entry
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SlidingEntryCounts(in BedOutputScanner, size float64, step float64) <-chan BedEntry {
+	s := NewSlider(in, size, step)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for s.Step() {
+			out <- s.CountEntry()
+		}
+		close(out)
+	}()
+	return out
+}
+
+// SlidingSyncSums writes the number of sync sites in each window.
 func SlidingSyncSums(inconn io.Reader, outconn io.Writer, size float64, step float64) {
 	b := NewSyncScanner(fasttsv.NewScanner(inconn))
-	s := NewSlider(b, size, step)
-	w := fasttsv.NewWriter(outconn)
-	defer w.Flush()
-
-	for s.Step() {
-		s.WriteWindow(w)
-	}
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	WriteEntries(w, NewBedEntryScanner(SlidingEntrySums(b, size, step)))
 }
@@ -228,3 +228,24 @@ func TestSlide(t *testing.T) {
 		})
 	}
 }
+
+var syncSites = `chr1	1	A	1:0:0:0:0:0
+chr1	2	A	1:0:0:0:0:0
+chr1	3	A	1:0:0:0:0:0
+chr1	7	A	1:0:0:0:0:0
+`
+
+func TestNewSyncScannerFirstSite(t *testing.T) {
+	s := NewSyncScanner(fasttsv.NewScanner(strings.NewReader(syncSites)))
+	if !s.Scan() || s.Entry().Left != 0 {
+		t.Errorf("first site %v, %v; want chr1:0-1", s.Entry(), s.Error())
+	}
+}
+
+func TestSlidingSyncSums(t *testing.T) {
+	var out strings.Builder
+	SlidingSyncSums(strings.NewReader(syncSites), &out, 5, 5)
+	if expect := "chr1\t0\t5\t3\nchr1\t5\t10\t1\n"; out.String() != expect {
+		t.Errorf("out %q != expect %q", out.String(), expect)
+	}
+}
@@ -0,0 +1,143 @@
+package slide
+
+import (
+	"io"
+	"math"
+)
+
+type SpanOptions struct {
+	// An entry is a hit if its Val is >= High or <= Low. Use +Inf or -Inf
+	// to disable one side. NaN values are never hits.
+	High float64
+	Low float64
+	// MaxGap is the largest distance in bp between a span and the next hit
+	// for the hit to be merged into the span. Use +Inf to merge all hits on
+	// a chromosome.
+	MaxGap float64
+	// Spans with fewer hits or shorter than MinLength bp, before
+	// extension, are dropped.
+	MinHits int
+	MinLength float64
+	// LeftExtend and RightExtend widen the reported spans. Spans are not
+	// extended past 0.
+	LeftExtend float64
+	RightExtend float64
+}
+
+// DefaultSpanOptions calls no hits until High or Low is set, and merges
+// only hits that touch or overlap.
+func DefaultSpanOptions() SpanOptions {
+	return SpanOptions{High: math.Inf(1), Low: math.Inf(-1)}
+}
+
+// SpanFields summarizes the hits of a span. Peak is the hit value furthest
+// beyond its threshold.
+type SpanFields struct {
+	Peak float64
+	Mean float64
+	Hits int
+}
+
+func (f SpanFields) Columns() []string {
+	return []string{"peak", "mean", "hits"}
+}
+
+func (f SpanFields) Values() []interface{} {
+	return []interface{}{f.Peak, f.Mean, f.Hits}
+}
+
+// excess returns how far v is beyond the thresholds of opts, and whether it
+// is a hit at all.
+func (o SpanOptions) excess(v float64) (float64, bool) {
+	switch {
+	case v >= o.High:
+		return v - o.High, true
+	case v <= o.Low:
+		return o.Low - v, true
+	}
+	return 0, false
+}
+
+type spanBuilder struct {
+	entry BedEntry
+	high bool
+	sum float64
+	peakExcess float64
+	fields SpanFields
+}
+
+func (s *spanBuilder) add(b BedEntry, excess float64) {
+	s.entry.Right = math.Max(s.entry.Right, b.Right)
+	s.sum += b.Val
+	s.fields.Hits++
+	if s.fields.Hits == 1 || excess > s.peakExcess {
+		s.peakExcess = excess
+		s.fields.Peak = b.Val
+	}
+}
+
+func (s *spanBuilder) finish(opts SpanOptions) (BedEntry, bool) {
+	if s.fields.Hits < opts.MinHits || s.entry.Right - s.entry.Left < opts.MinLength {
+		return BedEntry{}, false
+	}
+	b := s.entry
+	b.Left = math.Max(b.Left - opts.LeftExtend, 0)
+	b.Right += opts.RightExtend
+	s.fields.Mean = s.sum / float64(s.fields.Hits)
+	b.Val = s.fields.Peak
+	b.Other = s.fields
+	return b, true
+}
+
+// CallSpans merges the hits of in, which must be sorted by position within
+// each chromosome, into spans. Hits above High and below Low are never
+// merged into the same span. Each span has its peak value in Val and
+// SpanFields in Other.
+func CallSpans(in BedOutputScanner, opts SpanOptions) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		var cur *spanBuilder
+		flush := func() {
+			if cur == nil {
+				return
+			}
+			if b, ok := cur.finish(opts); ok {
+				out <- b
+			}
+			cur = nil
+		}
+
+		for in.Scan() {
+			b := in.Entry()
+			excess, hit := opts.excess(b.Val)
+			if !hit {
+				continue
+			}
+			high := b.Val >= opts.High
+			if cur != nil && (b.Chrom != cur.entry.Chrom || b.Left - cur.entry.Right > opts.MaxGap || high != cur.high) {
+				flush()
+			}
+			if cur == nil {
+				cur = &spanBuilder{entry: BedEntry{Chrom: b.Chrom, Left: b.Left, Right: b.Right}, high: high}
+			}
+			cur.add(b, excess)
+		}
+		flush()
+		close(out)
+	}()
+	return out
+}
+
+func CallSpansFull(inconn io.Reader, outconn io.Writer, opts SpanOptions) error {
+	h := handle("CallSpansFull: %w")
+	b := NewBedReaderScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(CallSpans(b, opts))); e != nil {
+		return h(e)
+	}
+	if e := b.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package slide
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCallSpans(t *testing.T) {
+	in := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 10, Val: 5},
+		{Chrom: "chr1", Left: 10, Right: 20, Val: 1},
+		{Chrom: "chr1", Left: 20, Right: 30, Val: 8},
+		{Chrom: "chr1", Left: 100, Right: 110, Val: 4},
+		{Chrom: "chr1", Left: 200, Right: 210, Val: -6},
+		{Chrom: "chr1", Left: 300, Right: 310, Val: math.NaN()},
+		{Chrom: "chr2", Left: 5, Right: 15, Val: 9},
+	}
+	opts := DefaultSpanOptions()
+	opts.High, opts.Low = 3, -3
+	opts.MaxGap = 10
+	opts.LeftExtend = 2
+
+	var out []BedEntry
+	for b := range CallSpans(NewBedSliceScanner(in), opts) {
+		out = append(out, b)
+	}
+	if len(out) != 4 {
+		t.Fatalf("got %v spans, want 4: %v", len(out), out)
+	}
+	first := out[0].Other.(SpanFields)
+	if out[0].Left != 0 || out[0].Right != 30 || first.Hits != 2 || first.Peak != 8 || first.Mean != 6.5 {
+		t.Errorf("first span %v %+v", out[0], first)
+	}
+	if out[2].Val != -6 || out[3].Chrom != "chr2" || out[3].Left != 3 {
+		t.Errorf("spans %v", out)
+	}
+
+	opts.MinHits = 2
+	n := 0
+	for range CallSpans(NewBedSliceScanner(in), opts) {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("%v spans with at least 2 hits, want 1", n)
+	}
+}
+
+func TestCallSpansSides(t *testing.T) {
+	in := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 10, Val: 5},
+		{Chrom: "chr1", Left: 10, Right: 20, Val: -5},
+		{Chrom: "chr1", Left: 20, Right: 30, Val: -4},
+		{Chrom: "chr1", Left: 30, Right: 40, Val: 6},
+	}
+	opts := DefaultSpanOptions()
+	opts.High, opts.Low = 3, -3
+	opts.MaxGap = math.Inf(1)
+
+	var out []BedEntry
+	for b := range CallSpans(NewBedSliceScanner(in), opts) {
+		out = append(out, b)
+	}
+	if len(out) != 3 {
+		t.Fatalf("got %v spans, want 3: %v", len(out), out)
+	}
+	low := out[1].Other.(SpanFields)
+	if out[1].Left != 10 || out[1].Right != 30 || low.Peak != -5 || low.Mean != -4.5 || low.Hits != 2 {
+		t.Errorf("low span %v %+v", out[1], low)
+	}
+}
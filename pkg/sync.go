@@ -0,0 +1,243 @@
+package slide
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SyncAlleles are the allele columns of a popoolation sync count field.
+const SyncAlleles = "ATCGN*"
+
+// SyncFields holds the reference base and the A:T:C:G:N:del counts of each
+// population at one sync site.
+type SyncFields struct {
+	Ref byte
+	Counts [][6]int64
+}
+
+func ParseSyncCounts(field string) ([6]int64, error) {
+	var out [6]int64
+	parts := strings.Split(field, ":")
+	if len(parts) != 6 {
+		return out, fmt.Errorf("ParseSyncCounts: %q does not have 6 counts", field)
+	}
+	for i, p := range parts {
+		if p == "." || p == "" {
+			continue
+		}
+		v, e := strconv.ParseInt(p, 10, 64)
+		if e != nil {
+			return out, fmt.Errorf("ParseSyncCounts: %w", e)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// ParseSyncFields parses the columns of a sync line after the position:
+// the reference base followed by one count field per population.
+func ParseSyncFields(line []string) (SyncFields, error) {
+	var f SyncFields
+	if len(line) < 1 || len(line[0]) < 1 {
+		return f, fmt.Errorf("ParseSyncFields: missing reference base")
+	}
+	f.Ref = line[0][0]
+	f.Counts = make([][6]int64, 0, len(line) - 1)
+	for _, field := range line[1:] {
+		c, e := ParseSyncCounts(field)
+		if e != nil {
+			return f, fmt.Errorf("ParseSyncFields: %w", e)
+		}
+		f.Counts = append(f.Counts, c)
+	}
+	return f, nil
+}
+
+func (f SyncFields) NPops() int {
+	return len(f.Counts)
+}
+
+// Coverage is the number of A, T, C and G reads in population pop.
+func (f SyncFields) Coverage(pop int) int64 {
+	c := f.Counts[pop]
+	return c[0] + c[1] + c[2] + c[3]
+}
+
+// MajorMinor returns the indices, into SyncAlleles, of the two most common
+// of A, T, C and G summed over all populations. Ties go to the allele
+// listed first.
+func (f SyncFields) MajorMinor() (major int, minor int) {
+	var totals [4]int64
+	for _, c := range f.Counts {
+		for i := range totals {
+			totals[i] += c[i]
+		}
+	}
+	major, minor = 0, 1
+	if totals[1] > totals[0] {
+		major, minor = 1, 0
+	}
+	for i := 2; i < 4; i++ {
+		if totals[i] > totals[major] {
+			major, minor = i, major
+		} else if totals[i] > totals[minor] {
+			minor = i
+		}
+	}
+	return major, minor
+}
+
+// MajorMinorCounts returns the counts of the site-wide major and minor
+// alleles in population pop.
+func (f SyncFields) MajorMinorCounts(pop int) (float64, float64) {
+	major, minor := f.MajorMinor()
+	return float64(f.Counts[pop][major]), float64(f.Counts[pop][minor])
+}
+
+// MinorCount is the site-wide minor allele count summed over populations.
+func (f SyncFields) MinorCount() int64 {
+	_, minor := f.MajorMinor()
+	var n int64
+	for _, c := range f.Counts {
+		n += c[minor]
+	}
+	return n
+}
+
+// MinorFreq is the frequency of the site-wide minor allele in population
+// pop among reads carrying the major or minor allele. It is NaN when there
+// are no such reads.
+func (f SyncFields) MinorFreq(pop int) float64 {
+	major, minor := f.MajorMinorCounts(pop)
+	return ratio(minor, major + minor)
+}
+
+type SyncWindowStats struct {
+	Sites int
+	Polymorphic int
+	MeanCoverage []float64
+	MeanMinorFreq []float64
+}
+
+func (s SyncWindowStats) Columns() []string {
+	out := []string{"sites", "polymorphic"}
+	for i := range s.MeanCoverage {
+		out = append(out, fmt.Sprintf("cov_%d", i + 1))
+	}
+	for i := range s.MeanMinorFreq {
+		out = append(out, fmt.Sprintf("maf_%d", i + 1))
+	}
+	return out
+}
+
+func (s SyncWindowStats) Values() []interface{} {
+	out := []interface{}{s.Sites, s.Polymorphic}
+	for _, v := range s.MeanCoverage {
+		out = append(out, v)
+	}
+	for _, v := range s.MeanMinorFreq {
+		out = append(out, v)
+	}
+	return out
+}
+
+// SyncStats summarises the sync sites in items. A site is polymorphic when
+// its minor allele is seen at least minCount times across populations.
+// Mean coverage is taken over all sites and mean minor allele frequency
+// over polymorphic sites.
+func SyncStats(items []BedEntry, minCount int64) SyncWindowStats {
+	if minCount < 1 {
+		minCount = 1
+	}
+	var out SyncWindowStats
+	var mafN []float64
+	for _, b := range items {
+		f, ok := b.Other.(SyncFields)
+		if !ok {
+			continue
+		}
+		if out.MeanCoverage == nil {
+			out.MeanCoverage = make([]float64, f.NPops())
+			out.MeanMinorFreq = make([]float64, f.NPops())
+			mafN = make([]float64, f.NPops())
+		}
+		if f.NPops() != len(out.MeanCoverage) {
+			continue
+		}
+
+		out.Sites++
+		major, minor := f.MajorMinor()
+		polymorphic := f.MinorCount() >= minCount
+		if polymorphic {
+			out.Polymorphic++
+		}
+		for pop, c := range f.Counts {
+			out.MeanCoverage[pop] += float64(f.Coverage(pop))
+			if maf := ratio(float64(c[minor]), float64(c[major] + c[minor])); polymorphic && !math.IsNaN(maf) {
+				out.MeanMinorFreq[pop] += maf
+				mafN[pop]++
+			}
+		}
+	}
+	for pop := range out.MeanCoverage {
+		out.MeanCoverage[pop] /= float64(out.Sites)
+		out.MeanMinorFreq[pop] = ratio(out.MeanMinorFreq[pop], mafN[pop])
+	}
+	return out
+}
+
+func nanSlice(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// SlidingSyncStats reports SyncStats for each window, with the number of
+// polymorphic sites in Val.
+func SlidingSyncStats(in BedOutputScanner, size float64, step float64, minCount int64) <-chan BedEntry {
+	s := NewSlider(in, size, step)
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		npops := 0
+		for s.Step() {
+			stats := SyncStats(s.Entries(), minCount)
+			if stats.MeanCoverage != nil {
+				npops = len(stats.MeanCoverage)
+			} else if npops > 0 {
+				stats.MeanCoverage = nanSlice(npops)
+				stats.MeanMinorFreq = nanSlice(npops)
+			}
+			out <- BedEntry{
+				Chrom: s.Chrom,
+				Left: s.Left,
+				Right: s.Right,
+				Val: float64(stats.Polymorphic),
+				Other: stats,
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SlidingSyncStatsFull(inconn io.Reader, outconn io.Writer, size float64, step float64, minCount int64) error {
+	h := handle("SlidingSyncStatsFull: %w")
+	if e := CheckWindow(size, step); e != nil {
+		return h(e)
+	}
+	b := NewSyncReaderScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingSyncStats(b, size, step, minCount))); e != nil {
+		return h(e)
+	}
+	if e := b.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package slide
+
+import (
+	"strings"
+	"testing"
+)
+
+var testSync = `2R	1	A	10:0:0:0:0:0	20:0:0:0:0:0
+2R	2	T	0:6:0:2:0:0	0:10:0:10:0:0
+2R	3	C	0:0:4:0:0:1	0:0:6:0:0:0
+2R	4	G	.:.:.:.:.:.	0:0:0:8:0:0
+`
+
+func TestSyncFields(t *testing.T) {
+	s := NewSyncReaderScanner(strings.NewReader(testSync))
+	var sites []BedEntry
+	for s.Scan() {
+		sites = append(sites, s.Entry())
+	}
+	if err := s.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 4 {
+		t.Fatalf("read %v sites, want 4", len(sites))
+	}
+
+	f := sites[1].Other.(SyncFields)
+	major, minor := f.MajorMinor()
+	if SyncAlleles[major] != 'T' || SyncAlleles[minor] != 'G' {
+		t.Errorf("major %c minor %c", SyncAlleles[major], SyncAlleles[minor])
+	}
+	if f.MinorFreq(0) != 0.25 || f.Coverage(1) != 20 {
+		t.Errorf("maf %v coverage %v", f.MinorFreq(0), f.Coverage(1))
+	}
+
+	stats := SyncStats(sites, 1)
+	if stats.Sites != 4 || stats.Polymorphic != 1 || stats.MeanCoverage[0] != 5.5 || stats.MeanMinorFreq[1] != 0.5 {
+		t.Errorf("stats %+v", stats)
+	}
+}
@@ -0,0 +1,177 @@
+package slide
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TimePoint maps a population column of a sync file to the generation,
+// treatment and replicate it was sampled from. Pop is 0-based.
+type TimePoint struct {
+	Pop int
+	Generation float64
+	Treatment string
+	Replicate string
+}
+
+type TrajectoryDesign []TimePoint
+
+// ReadTrajectoryDesign reads lines of population column (1-based, as in
+// popoolation2), generation, treatment and replicate. Lines starting with
+// '#' are skipped.
+func ReadTrajectoryDesign(r io.Reader) (TrajectoryDesign, error) {
+	h := handle("ReadTrajectoryDesign: %w")
+	var out TrajectoryDesign
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || fields[0][0] == '#' {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, h(fmt.Errorf("line %q has %v fields, want 4", s.Text(), len(fields)))
+		}
+		col, e := strconv.Atoi(fields[0])
+		if e != nil { return nil, h(e) }
+		if col < 1 {
+			return nil, h(fmt.Errorf("population column %v < 1", col))
+		}
+		gen, e := strconv.ParseFloat(fields[1], 64)
+		if e != nil { return nil, h(e) }
+		out = append(out, TimePoint{Pop: col - 1, Generation: gen, Treatment: fields[2], Replicate: fields[3]})
+	}
+	if e := s.Err(); e != nil {
+		return nil, h(e)
+	}
+	return out, nil
+}
+
+type SlopeOptions struct {
+	Design TrajectoryDesign
+	Treatment string
+	Control string
+	// MinCoverage is the minimum major plus minor allele count for a
+	// sample to be used in a fit.
+	MinCoverage float64
+}
+
+type SlopeFields struct {
+	Treatment float64
+	Control float64
+}
+
+func (f SlopeFields) Columns() []string {
+	return []string{"slope_treatment", "slope_control"}
+}
+
+func (f SlopeFields) Values() []interface{} {
+	return []interface{}{f.Treatment, f.Control}
+}
+
+func regressionSlope(x, y []float64) float64 {
+	if len(x) < 2 {
+		return math.NaN()
+	}
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(len(x))
+	my /= float64(len(y))
+	var sxy, sxx float64
+	for i := range x {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+	}
+	return ratio(sxy, sxx)
+}
+
+type replicateKey struct {
+	treatment string
+	replicate string
+}
+
+// treatmentSlope fits the minor allele frequency against generation for
+// each replicate of treatment and returns the mean slope.
+func treatmentSlope(f PopAlleleCounts, opts SlopeOptions, treatment string) float64 {
+	xs := map[replicateKey][]float64{}
+	ys := map[replicateKey][]float64{}
+	var keys []replicateKey
+	for _, tp := range opts.Design {
+		if tp.Treatment != treatment || tp.Pop >= f.NPops() {
+			continue
+		}
+		major, minor := f.MajorMinorCounts(tp.Pop)
+		n := major + minor
+		if n == 0 || n < opts.MinCoverage {
+			continue
+		}
+		k := replicateKey{tp.Treatment, tp.Replicate}
+		if _, ok := xs[k]; !ok {
+			keys = append(keys, k)
+		}
+		xs[k] = append(xs[k], tp.Generation)
+		ys[k] = append(ys[k], minor / n)
+	}
+
+	sum, count := 0.0, 0.0
+	for _, k := range keys {
+		if slope := regressionSlope(xs[k], ys[k]); !math.IsNaN(slope) {
+			sum += slope
+			count++
+		}
+	}
+	return ratio(sum, count)
+}
+
+// SiteSlopeDiffs fits per-replicate regressions of the site-wide minor
+// allele frequency on generation and emits, for each site, the mean
+// treatment slope minus the mean control slope in Val.
+func SiteSlopeDiffs(in BedOutputScanner, opts SlopeOptions) <-chan BedEntry {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for in.Scan() {
+			b := in.Entry()
+			f, ok := b.Other.(PopAlleleCounts)
+			if !ok {
+				continue
+			}
+			slopes := SlopeFields{
+				Treatment: treatmentSlope(f, opts, opts.Treatment),
+				Control: treatmentSlope(f, opts, opts.Control),
+			}
+			b.Val = slopes.Treatment - slopes.Control
+			b.Other = slopes
+			out <- b
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SlidingSlopeDiffs(in BedOutputScanner, size float64, step float64, opts SlopeOptions) <-chan BedEntry {
+	mustWindow(size, step)
+	return SlidingEntryMeans(NewBedEntryScanner(SiteSlopeDiffs(in, opts)), size, step)
+}
+
+func SlidingSyncSlopeDiffsFull(inconn io.Reader, outconn io.Writer, size float64, step float64, opts SlopeOptions) error {
+	h := handle("SlidingSyncSlopeDiffsFull: %w")
+	if e := CheckWindow(size, step); e != nil {
+		return h(e)
+	}
+	b := NewSyncReaderScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingSlopeDiffs(b, size, step, opts))); e != nil {
+		return h(e)
+	}
+	if e := b.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
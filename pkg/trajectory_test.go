@@ -0,0 +1,34 @@
+package slide
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+var testDesign = `# column generation treatment replicate
+1	0	sel	r1
+2	10	sel	r1
+3	0	ctl	r1
+4	10	ctl	r1
+`
+
+func TestSiteSlopeDiffs(t *testing.T) {
+	design, err := ReadTrajectoryDesign(strings.NewReader(testDesign))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sync := "2L\t5\tA\t90:10:0:0:0:0\t50:50:0:0:0:0\t90:10:0:0:0:0\t80:20:0:0:0:0\n"
+	opts := SlopeOptions{Design: design, Treatment: "sel", Control: "ctl"}
+
+	var out []BedEntry
+	for b := range SiteSlopeDiffs(NewSyncReaderScanner(strings.NewReader(sync)), opts) {
+		out = append(out, b)
+	}
+	if len(out) != 1 {
+		t.Fatalf("got %v sites, want 1", len(out))
+	}
+	if math.Abs(out[0].Val - (0.04 - 0.01)) > 1e-9 {
+		t.Errorf("slope difference %v != 0.03", out[0].Val)
+	}
+}
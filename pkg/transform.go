@@ -0,0 +1,123 @@
+package slide
+
+import (
+	"math"
+	"sort"
+)
+
+// MapEntries returns a stage that replaces each entry of in with f(entry).
+func MapEntries(in BedOutputScanner, f func(BedEntry) BedEntry) *BedEntryScanner {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for in.Scan() {
+			out <- f(in.Entry())
+		}
+		close(out)
+	}()
+	return NewBedEntryScanner(out)
+}
+
+// FilterEntries returns a stage that passes on the entries of in for which
+// keep is true.
+func FilterEntries(in BedOutputScanner, keep func(BedEntry) bool) *BedEntryScanner {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		for in.Scan() {
+			if b := in.Entry(); keep(b) {
+				out <- b
+			}
+		}
+		close(out)
+	}()
+	return NewBedEntryScanner(out)
+}
+
+// Transform applies f to the Val of each entry.
+func Transform(in BedOutputScanner, f func(float64) float64) *BedEntryScanner {
+	return MapEntries(in, func(b BedEntry) BedEntry {
+		b.Val = f(b.Val)
+		return b
+	})
+}
+
+func Abs(in BedOutputScanner) *BedEntryScanner {
+	return Transform(in, math.Abs)
+}
+
+// Log takes the logarithm of Val in the given base. Non-positive values
+// give -Inf or NaN, as math.Log does.
+func Log(in BedOutputScanner, base float64) *BedEntryScanner {
+	lb := math.Log(base)
+	return Transform(in, func(v float64) float64 {
+		return math.Log(v) / lb
+	})
+}
+
+// Scale replaces Val with Val * factor + offset.
+func Scale(in BedOutputScanner, factor float64, offset float64) *BedEntryScanner {
+	return Transform(in, func(v float64) float64 {
+		return v * factor + offset
+	})
+}
+
+// Clamp limits Val to [lo, hi]. NaN values are left as NaN.
+func Clamp(in BedOutputScanner, lo float64, hi float64) *BedEntryScanner {
+	return Transform(in, func(v float64) float64 {
+		if math.IsNaN(v) {
+			return v
+		}
+		return math.Min(math.Max(v, lo), hi)
+	})
+}
+
+// Threshold passes on the entries whose Val is at least t.
+func Threshold(in BedOutputScanner, t float64) *BedEntryScanner {
+	return FilterEntries(in, func(b BedEntry) bool {
+		return b.Val >= t
+	})
+}
+
+// Rank replaces Val with its 1-based rank among all values of in, averaging
+// ties. NaN values keep NaN and are not ranked. Rank reads all of in before
+// emitting anything.
+func Rank(in BedOutputScanner) *BedEntryScanner {
+	out := make(chan BedEntry, 256)
+
+	go func() {
+		var entries []BedEntry
+		var idx []int
+		for in.Scan() {
+			b := in.Entry()
+			if !math.IsNaN(b.Val) {
+				idx = append(idx, len(entries))
+			}
+			entries = append(entries, b)
+		}
+		sort.SliceStable(idx, func(i, j int) bool {
+			return entries[idx[i]].Val < entries[idx[j]].Val
+		})
+
+		ranks := make([]float64, len(idx))
+		for i := 0; i < len(idx); {
+			j := i
+			for j < len(idx) && entries[idx[j]].Val == entries[idx[i]].Val {
+				j++
+			}
+			for k := i; k < j; k++ {
+				ranks[k] = float64(i + j + 1) / 2
+			}
+			i = j
+		}
+		for i, e := range idx {
+			entries[e].Val = ranks[i]
+		}
+
+		for _, b := range entries {
+			out <- b
+		}
+		close(out)
+	}()
+	return NewBedEntryScanner(out)
+}
@@ -0,0 +1,220 @@
+package slide
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PopAlleleCounts is implemented by the Other values of sites carrying
+// allele counts for several populations, such as SyncFields and VcfFields.
+type PopAlleleCounts interface {
+	NPops() int
+	MajorMinorCounts(pop int) (major float64, minor float64)
+}
+
+// VcfFields holds the alleles of a VCF record and the number of called
+// copies of each allele in each population. Allele 0 is the reference.
+type VcfFields struct {
+	Ref string
+	Alt []string
+	Counts [][]int64
+}
+
+func (f VcfFields) NPops() int {
+	return len(f.Counts)
+}
+
+// MajorMinor returns the indices of the two most common alleles summed
+// over all populations.
+func (f VcfFields) MajorMinor() (major int, minor int) {
+	nalleles := len(f.Alt) + 1
+	totals := make([]int64, nalleles)
+	for _, c := range f.Counts {
+		for i := range totals {
+			totals[i] += c[i]
+		}
+	}
+	major, minor = 0, -1
+	for i := 1; i < nalleles; i++ {
+		if totals[i] > totals[major] {
+			major, minor = i, major
+		} else if minor < 0 || totals[i] > totals[minor] {
+			minor = i
+		}
+	}
+	return major, minor
+}
+
+func (f VcfFields) MajorMinorCounts(pop int) (float64, float64) {
+	major, minor := f.MajorMinor()
+	if minor < 0 {
+		return float64(f.Counts[pop][major]), 0
+	}
+	return float64(f.Counts[pop][major]), float64(f.Counts[pop][minor])
+}
+
+// ReadPopMap reads sample-to-population assignments as lines of sample
+// name and population name. Populations are numbered in order of first
+// appearance.
+func ReadPopMap(r io.Reader) (map[string]int, []string, error) {
+	h := handle("ReadPopMap: %w")
+	pops := map[string]int{}
+	index := map[string]int{}
+	var names []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || fields[0][0] == '#' {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, nil, h(fmt.Errorf("line %q has no population", s.Text()))
+		}
+		i, ok := index[fields[1]]
+		if !ok {
+			i = len(names)
+			index[fields[1]] = i
+			names = append(names, fields[1])
+		}
+		pops[fields[0]] = i
+	}
+	if e := s.Err(); e != nil {
+		return nil, nil, h(e)
+	}
+	return pops, names, nil
+}
+
+// VcfScanner reads VCF records, counting genotype calls per population.
+// Pops maps sample names to populations; samples missing from it are
+// ignored. With a nil map all samples form population 0.
+type VcfScanner struct {
+	s *bufio.Scanner
+	Pops map[string]int
+	Samples []string
+	NPops int
+	samplePop []int
+	cur BedEntry
+	err error
+}
+
+func NewVcfScanner(r io.Reader, pops map[string]int) *VcfScanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64 * 1024), 1 << 30)
+	return &VcfScanner{s: s, Pops: pops}
+}
+
+func (s *VcfScanner) readHeader(line string) {
+	fields := strings.Split(line, "\t")
+	if len(fields) > 9 {
+		s.Samples = fields[9:]
+	}
+	s.samplePop = make([]int, len(s.Samples))
+	s.NPops = 0
+	for i, name := range s.Samples {
+		pop := 0
+		if s.Pops != nil {
+			var ok bool
+			pop, ok = s.Pops[name]
+			if !ok {
+				pop = -1
+			}
+		}
+		s.samplePop[i] = pop
+		if pop + 1 > s.NPops {
+			s.NPops = pop + 1
+		}
+	}
+}
+
+func (s *VcfScanner) Scan() bool {
+	h := handle("VcfScanner.Scan: %w")
+	for s.s.Scan() {
+		line := s.s.Text()
+		if strings.HasPrefix(line, "#CHROM") {
+			s.readHeader(line)
+			continue
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if s.samplePop == nil {
+			s.err = h(fmt.Errorf("record before #CHROM header"))
+			return false
+		}
+		e := s.parse(strings.Split(line, "\t"))
+		if e != nil {
+			s.err = h(e)
+			return false
+		}
+		return true
+	}
+	if e := s.s.Err(); e != nil {
+		s.err = h(e)
+	}
+	return false
+}
+
+func (s *VcfScanner) parse(fields []string) error {
+	if len(fields) < 8 {
+		return fmt.Errorf("len(line) %v < 8", len(fields))
+	}
+	pos, e := strconv.ParseFloat(fields[1], 64)
+	if e != nil {
+		return e
+	}
+
+	var f VcfFields
+	f.Ref = fields[3]
+	if fields[4] != "." {
+		f.Alt = strings.Split(fields[4], ",")
+	}
+	f.Counts = make([][]int64, s.NPops)
+	for i := range f.Counts {
+		f.Counts[i] = make([]int64, len(f.Alt) + 1)
+	}
+
+	if len(fields) > 9 {
+		gtIndex := -1
+		for i, key := range strings.Split(fields[8], ":") {
+			if key == "GT" {
+				gtIndex = i
+			}
+		}
+		for i, sample := range fields[9:] {
+			if gtIndex < 0 || i >= len(s.samplePop) || s.samplePop[i] < 0 {
+				continue
+			}
+			parts := strings.Split(sample, ":")
+			if gtIndex >= len(parts) {
+				continue
+			}
+			for _, a := range strings.FieldsFunc(parts[gtIndex], func(r rune) bool { return r == '/' || r == '|' }) {
+				allele, e := strconv.Atoi(a)
+				if e != nil || allele < 0 || allele > len(f.Alt) {
+					continue
+				}
+				f.Counts[s.samplePop[i]][allele]++
+			}
+		}
+	}
+
+	s.cur = BedEntry{
+		Chrom: fields[0],
+		Left: pos - 1,
+		Right: pos - 1 + float64(len(f.Ref)),
+		Val: 1,
+		Other: f,
+	}
+	return nil
+}
+
+func (s *VcfScanner) Entry() BedEntry {
+	return s.cur
+}
+
+func (s *VcfScanner) Error() error {
+	return s.err
+}
@@ -0,0 +1,154 @@
+package slide
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type wigMode int
+
+const (
+	wigNone wigMode = iota
+	wigFixed
+	wigVariable
+)
+
+// WigScanner reads fixedStep and variableStep WIG tracks, converting the
+// 1-based positions to BED coordinates. Lines with four fields outside of
+// any declaration are read as bedGraph.
+type WigScanner struct {
+	s *bufio.Scanner
+	cur BedEntry
+	mode wigMode
+	chrom string
+	start float64
+	step float64
+	span float64
+	line int
+	err error
+}
+
+func NewWigScanner(r io.Reader) *WigScanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64 * 1024), 1 << 30)
+	return &WigScanner{s: s}
+}
+
+func (s *WigScanner) declare(fields []string) error {
+	mode := wigFixed
+	if fields[0] == "variableStep" {
+		mode = wigVariable
+	}
+	chrom := ""
+	start, step, span := 1.0, 1.0, 1.0
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("bad declaration field %q", f)
+		}
+		if kv[0] == "chrom" {
+			chrom = kv[1]
+			continue
+		}
+		v, e := strconv.ParseFloat(kv[1], 64)
+		if e != nil {
+			return e
+		}
+		switch kv[0] {
+		case "start":
+			start = v
+		case "step":
+			step = v
+		case "span":
+			span = v
+		}
+	}
+	if chrom == "" {
+		return fmt.Errorf("%v without chrom", fields[0])
+	}
+	s.mode, s.chrom, s.start, s.step, s.span = mode, chrom, start - 1, step, span
+	return nil
+}
+
+func (s *WigScanner) Scan() bool {
+	h := handle("WigScanner.Scan: %w")
+	for s.s.Scan() {
+		s.line++
+		line := strings.TrimSpace(s.s.Text())
+		if line == "" || line[0] == '#' || strings.HasPrefix(line, "track") || strings.HasPrefix(line, "browser") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] == "fixedStep" || fields[0] == "variableStep" {
+			if e := s.declare(fields); e != nil {
+				s.err = h(fmt.Errorf("line %v: %w", s.line, e))
+				return false
+			}
+			continue
+		}
+
+		var e error
+		switch {
+		case s.mode == wigFixed && len(fields) == 1:
+			s.cur = BedEntry{Chrom: s.chrom, Left: s.start, Right: s.start + s.span}
+			s.cur.Val, e = strconv.ParseFloat(fields[0], 64)
+			s.start += s.step
+		case s.mode == wigVariable && len(fields) == 2:
+			s.cur = BedEntry{Chrom: s.chrom}
+			s.cur.Left, e = strconv.ParseFloat(fields[0], 64)
+			s.cur.Left--
+			s.cur.Right = s.cur.Left + s.span
+			if e == nil {
+				s.cur.Val, e = strconv.ParseFloat(fields[1], 64)
+			}
+		case len(fields) == 4:
+			s.mode = wigNone
+			s.cur = BedEntry{Chrom: fields[0]}
+			s.cur.Left, e = strconv.ParseFloat(fields[1], 64)
+			if e == nil {
+				s.cur.Right, e = strconv.ParseFloat(fields[2], 64)
+			}
+			if e == nil {
+				s.cur.Val, e = strconv.ParseFloat(fields[3], 64)
+			}
+		default:
+			e = fmt.Errorf("unexpected data line %q", line)
+		}
+		if e != nil {
+			s.err = h(fmt.Errorf("line %v: %w", s.line, e))
+			return false
+		}
+		return true
+	}
+	if e := s.s.Err(); e != nil {
+		s.err = h(e)
+	}
+	return false
+}
+
+func (s *WigScanner) Entry() BedEntry {
+	return s.cur
+}
+
+func (s *WigScanner) Error() error {
+	return s.err
+}
+
+func SlidingWigMeansFull(inconn io.Reader, outconn io.Writer, size float64, step float64) error {
+	h := handle("SlidingWigMeansFull: %w")
+	if e := CheckWindow(size, step); e != nil {
+		return h(e)
+	}
+	wig := NewWigScanner(inconn)
+	w := NewTsvWriter(outconn, DefaultWriterOptions())
+	if e := WriteEntries(w, NewBedEntryScanner(SlidingEntryMeans(wig, size, step))); e != nil {
+		return h(e)
+	}
+	if e := wig.Error(); e != nil {
+		return h(e)
+	}
+	return nil
+}
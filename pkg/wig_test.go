@@ -0,0 +1,37 @@
+package slide
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testWig = `track type=wiggle_0 name=test
+fixedStep chrom=chr1 start=11 step=10 span=5
+1
+2.5
+variableStep chrom=chr2 span=2
+3	4
+10	-1
+`
+
+func TestWigScanner(t *testing.T) {
+	s := NewWigScanner(strings.NewReader(testWig))
+	var out []BedEntry
+	for s.Scan() {
+		out = append(out, s.Entry())
+	}
+	if err := s.Error(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []BedEntry{
+		{Chrom: "chr1", Left: 10, Right: 15, Val: 1},
+		{Chrom: "chr1", Left: 20, Right: 25, Val: 2.5},
+		{Chrom: "chr2", Left: 2, Right: 4, Val: 4},
+		{Chrom: "chr2", Left: 9, Right: 11, Val: -1},
+	}
+	if !reflect.DeepEqual(out, expect) {
+		t.Errorf("out %v != expect %v", out, expect)
+	}
+}
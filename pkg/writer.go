@@ -0,0 +1,389 @@
+package slide
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// EntryWriter writes BedEntry values in some output format. Flush must be
+// called once all entries are written.
+type EntryWriter interface {
+	Write(b BedEntry) error
+	Flush() error
+}
+
+// Columner is implemented by values stored in BedEntry.Other that add
+// named columns after the value column.
+type Columner interface {
+	Columns() []string
+	Values() []interface{}
+}
+
+type WriterOptions struct {
+	// Precision is the number of significant digits written for floats,
+	// or -1 for the shortest exact representation.
+	Precision int
+	// NA is written for NaN values.
+	NA string
+	// Header makes TSV output start with a #chrom line naming the columns.
+	Header bool
+	// TrackLine replaces the default bedGraph track line.
+	TrackLine string
+	// Comments are written as lines starting with "# " at the top of TSV,
+	// bedGraph and CSV output, and as a leading {"comments": [...]} record
+	// in NDJSON output.
+	Comments []string
+}
+
+func DefaultWriterOptions() WriterOptions {
+	return WriterOptions{Precision: -1, NA: "NaN"}
+}
+
+func (o WriterOptions) FormatFloat(v float64) string {
+	if math.IsNaN(v) {
+		return o.NA
+	}
+	return strconv.FormatFloat(v, 'g', o.Precision, 64)
+}
+
+func (o WriterOptions) formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case float64:
+		return o.FormatFloat(x)
+	case string:
+		if x == "" {
+			return o.NA
+		}
+		return x
+	case []string:
+		if len(x) == 0 {
+			return o.NA
+		}
+		return strings.Join(x, ",")
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// ExtraColumns returns the names and values of the columns that the Other
+// field of b adds: those of a Columner, or a single "other" column for a
+// float64. Other values add no columns.
+func ExtraColumns(b BedEntry) ([]string, []interface{}) {
+	switch o := b.Other.(type) {
+	case Columner:
+		return o.Columns(), o.Values()
+	case float64:
+		return []string{"other"}, []interface{}{o}
+	}
+	return nil, nil
+}
+
+// Fields formats b as chrom, start, end and value followed by any extra
+// columns.
+func (o WriterOptions) Fields(b BedEntry) []string {
+	out := []string{
+		b.Chrom,
+		strconv.FormatInt(int64(b.Left), 10),
+		strconv.FormatInt(int64(b.Right), 10),
+		o.FormatFloat(b.Val),
+	}
+	_, vals := ExtraColumns(b)
+	for _, v := range vals {
+		out = append(out, o.formatValue(v))
+	}
+	return out
+}
+
+func (o WriterOptions) writeComments(w io.Writer) error {
+	for _, c := range o.Comments {
+		if _, e := fmt.Fprintf(w, "# %s\n", c); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func headerNames(b BedEntry) []string {
+	names, _ := ExtraColumns(b)
+	return append([]string{"chrom", "start", "end", "value"}, names...)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type TsvWriter struct {
+	w *bufio.Writer
+	Opts WriterOptions
+	started bool
+}
+
+func NewTsvWriter(w io.Writer, opts WriterOptions) *TsvWriter {
+	return &TsvWriter{w: bufio.NewWriter(w), Opts: opts}
+}
+
+func (w *TsvWriter) Write(b BedEntry) error {
+	if !w.started {
+		w.started = true
+		if e := w.Opts.writeComments(w.w); e != nil {
+			return e
+		}
+		if w.Opts.Header {
+			if _, e := fmt.Fprintf(w.w, "#%s\n", strings.Join(headerNames(b), "\t")); e != nil {
+				return e
+			}
+		}
+	}
+	_, e := fmt.Fprintf(w.w, "%s\n", strings.Join(w.Opts.Fields(b), "\t"))
+	return e
+}
+
+func (w *TsvWriter) Flush() error {
+	if !w.started {
+		w.started = true
+		if e := w.Opts.writeComments(w.w); e != nil {
+			return e
+		}
+	}
+	return w.w.Flush()
+}
+
+type BedGraphWriter struct {
+	w *bufio.Writer
+	Opts WriterOptions
+	started bool
+}
+
+func NewBedGraphWriter(w io.Writer, opts WriterOptions) *BedGraphWriter {
+	return &BedGraphWriter{w: bufio.NewWriter(w), Opts: opts}
+}
+
+func (w *BedGraphWriter) Write(b BedEntry) error {
+	if !w.started {
+		w.started = true
+		track := w.Opts.TrackLine
+		if track == "" {
+			track = "track type=bedGraph"
+		}
+		if e := w.Opts.writeComments(w.w); e != nil {
+			return e
+		}
+		if _, e := fmt.Fprintf(w.w, "%s\n", track); e != nil {
+			return e
+		}
+	}
+	_, e := fmt.Fprintf(w.w, "%s\n", strings.Join(w.Opts.Fields(BedEntry{Chrom: b.Chrom, Left: b.Left, Right: b.Right, Val: b.Val}), "\t"))
+	return e
+}
+
+func (w *BedGraphWriter) Flush() error {
+	if !w.started {
+		w.started = true
+		if e := w.Opts.writeComments(w.w); e != nil {
+			return e
+		}
+	}
+	return w.w.Flush()
+}
+
+// CsvWriter writes comma separated values with a header taken from the
+// columns of the first entry. Entries with other columns are an error.
+type CsvWriter struct {
+	w *csv.Writer
+	out *bufio.Writer
+	Opts WriterOptions
+	started bool
+	header []string
+}
+
+func NewCsvWriter(w io.Writer, opts WriterOptions) *CsvWriter {
+	out := bufio.NewWriter(w)
+	return &CsvWriter{w: csv.NewWriter(out), out: out, Opts: opts}
+}
+
+func (w *CsvWriter) Write(b BedEntry) error {
+	if !w.started {
+		w.started = true
+		if e := w.Opts.writeComments(w.out); e != nil {
+			return e
+		}
+		w.header = headerNames(b)
+		if e := w.w.Write(w.header); e != nil {
+			return e
+		}
+	} else if names := headerNames(b); !equalStrings(names, w.header) {
+		return fmt.Errorf("CsvWriter: entry at %v:%v has columns %v, want %v", b.Chrom, b.Left, names, w.header)
+	}
+	return w.w.Write(w.Opts.Fields(b))
+}
+
+func (w *CsvWriter) Flush() error {
+	if !w.started {
+		w.started = true
+		if e := w.Opts.writeComments(w.out); e != nil {
+			return e
+		}
+	}
+	w.w.Flush()
+	if e := w.w.Error(); e != nil {
+		return e
+	}
+	return w.out.Flush()
+}
+
+// NdjsonWriter writes one JSON object per line. NaN values become null.
+type NdjsonWriter struct {
+	w *bufio.Writer
+	Opts WriterOptions
+	started bool
+}
+
+func (w *NdjsonWriter) writeComments() error {
+	w.started = true
+	if len(w.Opts.Comments) == 0 {
+		return nil
+	}
+	out, e := json.Marshal(map[string][]string{"comments": w.Opts.Comments})
+	if e != nil {
+		return e
+	}
+	_, e = fmt.Fprintf(w.w, "%s\n", out)
+	return e
+}
+
+func NewNdjsonWriter(w io.Writer, opts WriterOptions) *NdjsonWriter {
+	return &NdjsonWriter{w: bufio.NewWriter(w), Opts: opts}
+}
+
+func (w *NdjsonWriter) jsonValue(v interface{}) (string, error) {
+	if f, ok := v.(float64); ok {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "null", nil
+		}
+		return strconv.FormatFloat(f, 'g', w.Opts.Precision, 64), nil
+	}
+	out, e := json.Marshal(v)
+	return string(out), e
+}
+
+func (w *NdjsonWriter) Write(b BedEntry) error {
+	if !w.started {
+		if e := w.writeComments(); e != nil {
+			return e
+		}
+	}
+	names := []string{"chrom", "start", "end", "value"}
+	vals := []interface{}{b.Chrom, int64(b.Left), int64(b.Right), b.Val}
+	extraNames, extraVals := ExtraColumns(b)
+	names = append(names, extraNames...)
+	vals = append(vals, extraVals...)
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		val, e := w.jsonValue(vals[i])
+		if e != nil {
+			return e
+		}
+		sb.Write(key)
+		sb.WriteByte(':')
+		sb.WriteString(val)
+	}
+	sb.WriteString("}\n")
+	_, e := w.w.WriteString(sb.String())
+	return e
+}
+
+func (w *NdjsonWriter) Flush() error {
+	if !w.started {
+		if e := w.writeComments(); e != nil {
+			return e
+		}
+	}
+	return w.w.Flush()
+}
+
+var EntryWriterFormats = []string{"tsv", "bed", "bedgraph", "csv", "ndjson"}
+
+func NewEntryWriter(format string, w io.Writer, opts WriterOptions) (EntryWriter, error) {
+	switch strings.ToLower(format) {
+	case "tsv", "bed", "":
+		return NewTsvWriter(w, opts), nil
+	case "bedgraph":
+		return NewBedGraphWriter(w, opts), nil
+	case "csv":
+		return NewCsvWriter(w, opts), nil
+	case "ndjson", "jsonl":
+		return NewNdjsonWriter(w, opts), nil
+	}
+	return nil, fmt.Errorf("NewEntryWriter: unknown format %q; choose one of %v", format, strings.Join(EntryWriterFormats, ", "))
+}
+
+// WriteEntries writes every entry of in and flushes w. If writing fails
+// the rest of in is drained so that upstream goroutines can finish.
+func WriteEntries(w EntryWriter, in BedOutputScanner) error {
+	h := handle("WriteEntries: %w")
+	for in.Scan() {
+		if e := w.Write(in.Entry()); e != nil {
+			for in.Scan() {
+			}
+			return h(e)
+		}
+	}
+	if e := w.Flush(); e != nil {
+		return h(e)
+	}
+	if e := scanErr(in); e != nil {
+		return h(e)
+	}
+	return nil
+}
+
+// WriterFlags registers the output flags shared by the commands. A Format
+// or NA set before Register is used as the flag default.
+type WriterFlags struct {
+	Format string
+	Precision int
+	NA string
+	Header bool
+}
+
+func (f *WriterFlags) Register(fs *flag.FlagSet) {
+	if f.Format == "" {
+		f.Format = "tsv"
+	}
+	if f.NA == "" {
+		f.NA = "NaN"
+	}
+	fs.StringVar(&f.Format, "format", f.Format, "Output format: " + strings.Join(EntryWriterFormats, ", "))
+	fs.IntVar(&f.Precision, "precision", -1, "Significant digits for floating point output (-1 for shortest exact)")
+	fs.StringVar(&f.NA, "na", f.NA, "Token written for missing values")
+	fs.BoolVar(&f.Header, "header", false, "Write a header line naming the columns (tsv)")
+}
+
+func (f *WriterFlags) Options() WriterOptions {
+	return WriterOptions{Precision: f.Precision, NA: f.NA, Header: f.Header}
+}
+
+func (f *WriterFlags) NewWriter(w io.Writer) (EntryWriter, error) {
+	return NewEntryWriter(f.Format, w, f.Options())
+}
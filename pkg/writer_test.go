@@ -0,0 +1,74 @@
+package slide
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEntryWriters(t *testing.T) {
+	entries := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 10, Val: 1.0 / 3},
+		{Chrom: "chr1", Left: 10, Right: 20, Val: math.NaN(), Other: 2.5},
+	}
+	opts := WriterOptions{Precision: 3, NA: "NA"}
+
+	tests := []struct {
+		Format string
+		Expect string
+		Err bool
+	}{
+		{"tsv", "chr1\t0\t10\t0.333\nchr1\t10\t20\tNA\t2.5\n", false},
+		{"bedgraph", "track type=bedGraph\nchr1\t0\t10\t0.333\nchr1\t10\t20\tNA\n", false},
+		{"csv", "", true},
+		{"ndjson", "{\"chrom\":\"chr1\",\"start\":0,\"end\":10,\"value\":0.333}\n{\"chrom\":\"chr1\",\"start\":10,\"end\":20,\"value\":null,\"other\":2.5}\n", false},
+	}
+
+	for _, test := range tests {
+		var out strings.Builder
+		w, err := NewEntryWriter(test.Format, &out, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = WriteEntries(w, NewBedSliceScanner(entries))
+		if test.Err {
+			if err == nil {
+				t.Errorf("%v: ragged entries written without an error", test.Format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.Expect {
+			t.Errorf("%v: out %q != expect %q", test.Format, out.String(), test.Expect)
+		}
+	}
+}
+
+func TestCsvWriter(t *testing.T) {
+	entries := []BedEntry{
+		{Chrom: "chr1", Left: 0, Right: 10, Val: 1, Other: 0.5},
+		{Chrom: "chr1", Left: 10, Right: 20, Val: math.NaN(), Other: 2.5},
+	}
+	var out strings.Builder
+	if err := WriteEntries(NewCsvWriter(&out, WriterOptions{Precision: -1, NA: "NA"}), NewBedSliceScanner(entries)); err != nil {
+		t.Fatal(err)
+	}
+	expect := "chrom,start,end,value,other\nchr1,0,10,1,0.5\nchr1,10,20,NA,2.5\n"
+	if out.String() != expect {
+		t.Errorf("out %q != expect %q", out.String(), expect)
+	}
+}
+
+func TestNdjsonComments(t *testing.T) {
+	var out strings.Builder
+	w := NewNdjsonWriter(&out, WriterOptions{Precision: -1, Comments: []string{"a: 1", "b"}})
+	if err := WriteEntries(w, NewBedSliceScanner([]BedEntry{{Chrom: "chr1", Left: 0, Right: 1, Val: 2}})); err != nil {
+		t.Fatal(err)
+	}
+	expect := "{\"comments\":[\"a: 1\",\"b\"]}\n{\"chrom\":\"chr1\",\"start\":0,\"end\":1,\"value\":2}\n"
+	if out.String() != expect {
+		t.Errorf("out %q != expect %q", out.String(), expect)
+	}
+}
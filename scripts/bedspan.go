@@ -1,25 +1,18 @@
 package main
 
 import (
-	"io/ioutil"
-	"os/exec"
 	"errors"
 	"bufio"
 	"sort"
-	"fmt"
 	"github.com/jgbaldwinbrown/fasttsv"
+	"github.com/jgbaldwinbrown/slide/pkg"
+	"math"
 	"io"
 	"strconv"
 	"os"
 	"flag"
 )
 
-type span struct {
-	chrom string
-	start int
-	end int
-}
-
 type flag_args struct {
 	high_threshold float64
 	low_threshold float64
@@ -28,31 +21,26 @@ type flag_args struct {
 	intersect string
 	left_extend int
 	right_extend int
+	max_gap float64
+	min_hits int
+	min_length float64
+	writer slide.WriterFlags
 }
 
-type hit_entry struct {
-	line []string
-	chrom string
-	pos int
-	s_val float64
-}
-
-type hit_list []hit_entry
-
-func (l hit_list) Len() int {return len(l)}
-func (l hit_list) Swap(i, j int) {l[i], l[j] = l[j], l[i]}
+type ByChromAndPos []slide.BedEntry
 
-type ByChromAndPos struct {hit_list}
+func (l ByChromAndPos) Len() int {return len(l)}
+func (l ByChromAndPos) Swap(i, j int) {l[i], l[j] = l[j], l[i]}
 
-func (s ByChromAndPos) Less(i, j int) bool {
-	a := s.hit_list[i]
-	b := s.hit_list[j]
-	if a.chrom < b.chrom {
+func (l ByChromAndPos) Less(i, j int) bool {
+	a := l[i]
+	b := l[j]
+	if a.Chrom < b.Chrom {
 		return true
-	} else if a.chrom > b.chrom {
+	} else if a.Chrom > b.Chrom {
 		return false
 	} else {
-		return a.pos < b.pos
+		return a.Left < b.Left
 	}
 }
 
@@ -65,6 +53,10 @@ func get_flags() flag_args {
 	flag.StringVar(&out.suffix, "s", "bedspan_out", "Suffix for output files.")
 	flag.IntVar(&out.left_extend, "l", 0, "Extend bed entries left by this amount.")
 	flag.IntVar(&out.right_extend, "r", 0, "Extend bed entries right by this amount.")
+	flag.Float64Var(&out.max_gap, "g", 0, "Merge hits at most this many bp apart (0 merges touching hits; -1 merges all hits on a chromosome).")
+	flag.IntVar(&out.min_hits, "n", 0, "Drop spans with fewer hits than this.")
+	flag.Float64Var(&out.min_length, "minlen", 0, "Drop spans shorter than this before extension.")
+	out.writer.Register(flag.CommandLine)
 	flag.Parse()
 	var err error
 	out.high_threshold, err = strconv.ParseFloat(*high_str, 64)
@@ -74,164 +66,124 @@ func get_flags() flag_args {
 	return out
 }
 
-func get_hits(r io.Reader, high_threshold float64, low_threshold float64) (hit_list, error) {
-	var out hit_list
-	var len_err error
+func (f flag_args) span_options() slide.SpanOptions {
+	opts := slide.SpanOptions{
+		High: f.high_threshold,
+		Low: f.low_threshold,
+		MaxGap: f.max_gap,
+		MinHits: f.min_hits,
+		MinLength: f.min_length,
+		LeftExtend: float64(f.left_extend),
+		RightExtend: float64(f.right_extend),
+	}
+	if opts.MaxGap < 0 {
+		opts.MaxGap = math.Inf(1)
+	}
+	return opts
+}
+
+// get_sites reads chrom, 1-based position and value columns after a header
+// line. Lines whose value or position does not parse are skipped.
+func get_sites(r io.Reader) ([]slide.BedEntry, error) {
+	var out []slide.BedEntry
 	scanner := fasttsv.NewScanner(r)
 	scanner.Scan()
 	for scanner.Scan() {
 		if len(scanner.Line()) < 3 {
-			len_err = errors.New("Error: Line does not have enough field")
-			return out, len_err
+			return out, errors.New("Error: Line does not have enough field")
 		}
 		s_val, err := strconv.ParseFloat(scanner.Line()[2], 64)
 		if err != nil {
 			continue
 		}
 		pos, err := strconv.Atoi(scanner.Line()[1])
-		if s_val >= high_threshold || s_val <= low_threshold {
-			hit_line := make([]string, len(scanner.Line()))
-			copy(hit_line, scanner.Line())
-			chrom := scanner.Line()[0]
-			if err != nil {continue}
-			out = append(out, hit_entry{hit_line, chrom, pos, s_val})
-		}
+		if err != nil {continue}
+		out = append(out, slide.BedEntry{Chrom: scanner.Line()[0], Left: float64(pos - 1), Right: float64(pos), Val: s_val})
 	}
-	return out, len_err
-}
-
-func get_spans_from_sorted_hits(h hit_list) []span {
-	var out []span
-	prev_chrom := ""
-	var s span
-	for _, hit := range h {
-		if hit.chrom != prev_chrom {
-			if prev_chrom != "" {
-				out = append(out, s)
-			}
-			s = span{chrom: hit.chrom, start: hit.pos - 1, end: hit.pos}
-		} else {
-			s.end = hit.pos
-		}
-		prev_chrom = hit.chrom
-	}
-	out = append(out, s)
-	return out
-}
-
-func extend_spans(s []span, left int, right int) []span {
-	var out []span
-	for _, aspan := range s {
-		if aspan.start - left >= 0 {
-			aspan.start -= left
-		} else {
-			aspan.start = 0
-		}
-		aspan.end += right
-		out = append(out, aspan)
-	}
-	return out
+	return out, nil
 }
 
-func print_spans(spans []span, w io.Writer) {
-	for _, s := range spans {
-		fmt.Fprintf(w, "%s\t%d\t%d\n", s.chrom, s.start, s.end)
+// print_spans writes each span with its peak value and SpanFields, if any.
+func print_spans(spans slide.BedOutputScanner, w io.Writer, wf slide.WriterFlags) error {
+	ew, err := wf.NewWriter(w)
+	if err != nil {
+		return err
 	}
+	return slide.WriteEntries(ew, spans)
 }
 
-func get_span(r io.Reader, w io.Writer, high_threshold float64, low_threshold float64, left_extend int, right_extend int) error {
-	hits, err := get_hits(r, high_threshold, low_threshold)
+// get_span writes the spans of the sites in r to w and returns them.
+func get_span(r io.Reader, w io.Writer, flags flag_args) ([]slide.BedEntry, error) {
+	sites, err := get_sites(r)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	sort.Stable(ByChromAndPos(sites))
+	var spans []slide.BedEntry
+	for b := range slide.CallSpans(slide.NewBedSliceScanner(sites), flags.span_options()) {
+		spans = append(spans, b)
 	}
-	sort.Sort(ByChromAndPos{hits})
-	spans := get_spans_from_sorted_hits(hits)
-	long_spans := extend_spans(spans, left_extend, right_extend)
-	print_spans(long_spans, w)
-	return err
+	return spans, print_spans(slide.NewBedSliceScanner(spans), w, flags.writer)
 }
 
-func get_spans_multi_file(r io.Reader, high_threshold float64, low_threshold float64, suffix string, left_extend int, right_extend int) []string {
-	var out []string
+// get_spans_multi_file writes the spans of each listed file next to it and
+// returns the spans of the files that succeeded, to be intersected without
+// reading back the formatted output.
+func get_spans_multi_file(r io.Reader, flags flag_args) [][]slide.BedEntry {
+	var out [][]slide.BedEntry
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		file, err := os.Open(scanner.Text())
 		if err != nil {panic(err)}
 		defer file.Close()
-		outfile, err := os.Create(scanner.Text() + "_" + suffix)
+		outfile, err := os.Create(scanner.Text() + "_" + flags.suffix)
 		if err != nil {panic(err)}
 		defer outfile.Close()
-		err = get_span(file, outfile, high_threshold, low_threshold, left_extend, right_extend)
+		spans, err := get_span(file, outfile, flags)
 		if err != nil {
-			err = os.Remove(scanner.Text() + "_" + suffix)
+			err = os.Remove(scanner.Text() + "_" + flags.suffix)
 			if err != nil {
 				panic(err)
 			}
 		} else {
-			out = append(out, scanner.Text() + "_" + suffix)
+			out = append(out, spans)
 		}
 	}
 	return out
 }
 
-func intersect_all_paths(paths []string, w io.Writer) {
-	input_temp, err := ioutil.TempFile("./", "input_temp")
-	if err != nil { panic(err) }
-
-	output_temp, err := ioutil.TempFile("./", "output_temp")
-	if err != nil { panic(err) }
-
-	defer os.Remove(input_temp.Name())
-	defer os.Remove(output_temp.Name())
-	in_name := input_temp.Name()
-	out_name := output_temp.Name()
-	if len(paths) < 2 { return }
-
-	cur_conn, err := os.Open(paths[0])
-	if err != nil { panic(err) }
-
-	defer cur_conn.Close()
-	_, err = io.Copy(input_temp, cur_conn)
-	if err != nil { panic(err) }
-
-	input_temp.Close()
-	output_temp.Close()
-	for _, path := range paths[1:] {
-		output_conn, err := os.Create(out_name)
-		if err != nil { panic(err) }
-
-		command := []string{"bedtools", "intersect", "-a", in_name, "-b", path}
-		cmd := exec.Command(command[0], command[1:]...)
-		cmd.Stdout = output_conn
-		cmd.Run()
-		output_conn.Close()
+// intersect_all_spans writes the bases covered by the spans of every file.
+// The spans of each file are sorted, so they are intersected in one pass.
+func intersect_all_spans(spans [][]slide.BedEntry, w io.Writer, wf slide.WriterFlags) error {
+	var ins []slide.BedOutputScanner
+	for _, s := range spans {
+		ins = append(ins, slide.NewBedSliceScanner(s))
+	}
+	return print_spans(slide.NewBedEntryScanner(slide.IntersectAll(ins...)), w, wf)
+}
 
-		input_conn, err := os.Create(in_name)
-		if err != nil {panic(err)}
-		output_conn, err = os.Open(out_name)
-		io.Copy(input_conn, output_conn)
-		input_conn.Close()
-		output_conn.Close()
+// multi_file writes the spans of each file listed in r and, with -i,
+// their intersection.
+func multi_file(r io.Reader, flags flag_args) error {
+	spans := get_spans_multi_file(r, flags)
+	if flags.intersect == "" {
+		return nil
 	}
-	output_conn, err := os.Open(out_name)
-	if err != nil {panic(err)}
-	io.Copy(w, output_conn)
-	output_conn.Close()
+	intersect_conn, err := os.Create(flags.intersect)
+	if err != nil {
+		return err
+	}
+	defer intersect_conn.Close()
+	return intersect_all_spans(spans, intersect_conn, flags.writer)
 }
 
 func main() {
 	flags := get_flags()
 	if ! flags.multi_file {
-		_ = get_span(os.Stdin, os.Stdout, flags.high_threshold, flags.low_threshold, flags.left_extend, flags.right_extend)
+		_, _ = get_span(os.Stdin, os.Stdout, flags)
 	} else {
-		output_paths := get_spans_multi_file(os.Stdin, flags.high_threshold, flags.low_threshold, flags.suffix, flags.left_extend, flags.right_extend)
-		if flags.intersect != "" {
-			intersect_conn, err := os.Create(flags.intersect)
-			if err != nil {
-				panic(err)
-			}
-			defer intersect_conn.Close()
-			intersect_all_paths(output_paths, intersect_conn)
+		if err := multi_file(os.Stdin, flags); err != nil {
+			panic(err)
 		}
 	}
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jgbaldwinbrown/slide/pkg"
+)
+
+func writeSites(t *testing.T, dir, name string, first, last int) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("chrom\tpos\tval\n")
+	for i := first; i <= last; i++ {
+		b.WriteString("chr1\t" + strconv.Itoa(i) + "\t5\n")
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMultiFileIntersectFormat(t *testing.T) {
+	dir := t.TempDir()
+	a := writeSites(t, dir, "a", 1, 10)
+	b := writeSites(t, dir, "b", 6, 15)
+	flags := flag_args{
+		high_threshold: 3,
+		low_threshold: math.Inf(-1),
+		multi_file: true,
+		suffix: "spans",
+		intersect: filepath.Join(dir, "both.csv"),
+		writer: slide.WriterFlags{Format: "csv", NA: "NaN", Precision: -1, Header: true},
+	}
+	if err := multi_file(strings.NewReader(a + "\n" + b + "\n"), flags); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(flags.intersect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "chrom,start,end,value,peak,mean,hits\nchr1,5,10,5,5,5,10\n"
+	if string(got) != want {
+		t.Errorf("intersection = %q, want %q", got, want)
+	}
+	spans, err := os.ReadFile(b + "_spans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "chrom,start,end,value,peak,mean,hits\nchr1,5,15,5,5,5,10\n"
+	if string(spans) != want {
+		t.Errorf("spans of b = %q, want %q", spans, want)
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"flag"
 	"math"
 	"strconv"
-	"fmt"
 	"github.com/jgbaldwinbrown/fasttsv"
 	"github.com/montanaflynn/stats"
+	"github.com/jgbaldwinbrown/slide/pkg"
 	"container/list"
 	"os"
 	"io"
@@ -25,10 +25,6 @@ type BedScanner struct {
 	LastErr error
 }
 
-type LineWriter interface {
-	Write([]string)
-}
-
 func NewBedScanner(s *fasttsv.Scanner) *BedScanner {
 	b := &BedScanner{Scanner: s}
 	b.Scan()
@@ -162,36 +158,37 @@ func (s *Slider) Mean() (float64, error) {
 	return stats.Mean(vals)
 }
 
-func (s *Slider) WriteWindow(w LineWriter) {
+func (s *Slider) WriteWindow(w slide.EntryWriter) error {
 	mean, err := s.Mean()
-	if err == nil {
-		w.Write([]string{s.Chrom, fmt.Sprintf("%d", int(s.Left)), fmt.Sprintf("%d", int(s.Right)), fmt.Sprintf("%g", mean)})
-	} else {
-		w.Write([]string{s.Chrom, fmt.Sprintf("%d", int(s.Left)), fmt.Sprintf("%d", int(s.Right)), "NA"})
+	if err != nil {
+		mean = math.NaN()
 	}
+	return w.Write(slide.BedEntry{Chrom: s.Chrom, Left: s.Left, Right: s.Right, Val: mean})
 }
 
-func SlidingMeans(inconn io.Reader, outconn io.Writer, size float64, step float64) {
+func SlidingMeans(inconn io.Reader, w slide.EntryWriter, size float64, step float64) error {
 	b := NewBedScanner(fasttsv.NewScanner(inconn))
 	s := NewSlider(b, size, step)
-	w := fasttsv.NewWriter(outconn)
-	defer w.Flush()
 
 	for s.Step() {
-		// fmt.Println(s)
-		// fmt.Println(s.Scanner.Entry())
-		s.WriteWindow(w)
+		if err := s.WriteWindow(w); err != nil {
+			return err
+		}
 	}
-	// fmt.Printf("%s\n", b.LastErr)
+	return w.Flush()
 }
 
 func main() {
-	winsize_strptr := flag.String("w", "", "Window size for sliding window")
-	winstep_strptr := flag.String("s", "", "Window step for sliding window")
+	var win slide.WindowFlags
+	win.Register(flag.CommandLine)
+	win.RegisterLegacy(flag.CommandLine, "w", "s")
+	wf := slide.WriterFlags{NA: "NA"}
+	wf.Register(flag.CommandLine)
 	flag.Parse()
-	winsize, err := strconv.ParseFloat(*winsize_strptr, 64)
+	winsize, winstep, err := win.Parse()
+	if err != nil { panic(err) }
+	w, err := wf.NewWriter(os.Stdout)
 	if err != nil { panic(err) }
-	winstep, err := strconv.ParseFloat(*winstep_strptr, 64)
+	err = SlidingMeans(os.Stdin, w, winsize, winstep)
 	if err != nil { panic(err) }
-	SlidingMeans(os.Stdin, os.Stdout, winsize, winstep)
 }